@@ -0,0 +1,75 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 视频源类型，对应配置中的 code.type
+const (
+	SourceTypeMacCMSJSON = "maccms_json"
+	SourceTypeMacCMSXML  = "maccms_xml"
+)
+
+// SourceQuery 源列表查询参数
+type SourceQuery struct {
+	Keyword string
+	Page    string
+	TypeID  string // 分类ID，对应 MacCMS 的 t 参数
+	Hours   string // 最近N小时内更新，对应 MacCMS 的 h 参数
+}
+
+// Category 源分类
+type Category struct {
+	TypeID   string `json:"type_id"`
+	TypePID  string `json:"type_pid"`
+	TypeName string `json:"type_name"`
+}
+
+// SourceAdapter 视频源协议适配器，每种采集接口协议对应一个实现
+type SourceAdapter interface {
+	// Search 按关键词搜索
+	Search(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error)
+	// Latest 获取最近更新列表
+	Latest(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error)
+	// Detail 按视频ID获取完整信息，支持多个ID
+	Detail(ctx context.Context, source *VideoSource, ids []string) ([]VideoItem, error)
+	// Categories 获取分类列表
+	Categories(ctx context.Context, source *VideoSource) ([]Category, error)
+}
+
+var (
+	adaptersMu     sync.RWMutex
+	sourceAdapters = map[string]SourceAdapter{
+		SourceTypeMacCMSJSON: &macCMSJSONAdapter{},
+		SourceTypeMacCMSXML:  &macCMSXMLAdapter{},
+	}
+)
+
+// RegisterSourceAdapter 注册视频源适配器，同名适配器会被覆盖
+func RegisterSourceAdapter(sourceType string, adapter SourceAdapter) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	sourceAdapters[strings.ToLower(sourceType)] = adapter
+}
+
+// GetSourceAdapter 根据源类型获取适配器，类型为空时使用 maccms_json
+func GetSourceAdapter(sourceType string) (SourceAdapter, error) {
+	if sourceType == "" {
+		sourceType = SourceTypeMacCMSJSON
+	}
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	adapter, ok := sourceAdapters[strings.ToLower(sourceType)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的源类型: %s", sourceType)
+	}
+	return adapter, nil
+}
+
+// adapterFor 获取视频源对应的适配器
+func adapterFor(source *VideoSource) (SourceAdapter, error) {
+	return GetSourceAdapter(source.Type)
+}
@@ -0,0 +1,233 @@
+package components
+
+import (
+	"log"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vastproxy-go/utils"
+)
+
+// digitsPattern 用于把路径中的数字归一化，得到分片路径规律
+var digitsPattern = regexp.MustCompile(`\d+`)
+
+// HLSAdFilter HLS 播放列表广告过滤器
+type HLSAdFilter struct {
+	hostMismatch  bool
+	pathMismatch  bool
+	durations     []float64
+	maxAdDuration float64
+	rules         []adFilterRule // 按域名长度降序，优先匹配更具体的域名
+}
+
+// adFilterRule 按域名配置的分片过滤规则
+type adFilterRule struct {
+	domain string
+	re     *regexp.Regexp
+}
+
+// NewHLSAdFilter 根据 [adfilter] 配置创建过滤器，未启用时返回 nil
+func NewHLSAdFilter(cfg *utils.Config) *HLSAdFilter {
+	if cfg == nil || !cfg.AdFilter.Enabled {
+		return nil
+	}
+
+	f := &HLSAdFilter{
+		hostMismatch:  cfg.AdFilter.HostMismatch,
+		pathMismatch:  cfg.AdFilter.PathMismatch,
+		maxAdDuration: cfg.AdFilter.MaxAdDuration,
+	}
+	for _, value := range strings.Split(cfg.AdFilter.AdDurations, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		d, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Printf("⚠️ 广告过滤: 无效的分片时长 %q", value)
+			continue
+		}
+		f.durations = append(f.durations, d)
+	}
+	for domain, expr := range cfg.AdFilter.Rules {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("⚠️ 广告过滤: 域名 %s 的正则无效: %v", domain, err)
+			continue
+		}
+		f.rules = append(f.rules, adFilterRule{domain: strings.ToLower(domain), re: re})
+	}
+	sort.Slice(f.rules, func(i, j int) bool {
+		if len(f.rules[i].domain) != len(f.rules[j].domain) {
+			return len(f.rules[i].domain) > len(f.rules[j].domain)
+		}
+		return f.rules[i].domain < f.rules[j].domain
+	})
+	return f
+}
+
+// Filter 移除媒体播放列表中的广告分片，返回处理后的播放列表和移除的分片数量；
+// 主播放列表或未发现广告时原样返回
+func (f *HLSAdFilter) Filter(body []byte, base *url.URL) ([]byte, int) {
+	playlist := parseHLSMediaPlaylist(body)
+	if playlist == nil {
+		return body, 0
+	}
+
+	removed := f.filterPlaylist(playlist, base)
+	if removed == 0 {
+		return body, 0
+	}
+	playlist.restoreSegmentState()
+	return playlist.Bytes(), removed
+}
+
+// filterPlaylist 从解析后的播放列表中移除广告分片，返回移除的分片数量
+func (f *HLSAdFilter) filterPlaylist(playlist *hlsMediaPlaylist, base *url.URL) int {
+	removed := f.applyRules(playlist, base)
+	if len(playlist.Groups) > 1 {
+		removed += f.dropAdGroups(playlist, base)
+	}
+	return removed
+}
+
+// applyRules 按播放列表所属域名的正则规则移除分片
+func (f *HLSAdFilter) applyRules(playlist *hlsMediaPlaylist, base *url.URL) int {
+	host := strings.ToLower(base.Hostname())
+	var rule *regexp.Regexp
+	for _, r := range f.rules {
+		if host == r.domain || strings.HasSuffix(host, "."+r.domain) {
+			rule = r.re
+			break
+		}
+	}
+	if rule == nil {
+		return 0
+	}
+
+	removed := 0
+	groups := playlist.Groups[:0]
+	for _, group := range playlist.Groups {
+		kept := group[:0]
+		for _, segment := range group {
+			if rule.MatchString(resolveSegmentURL(base, segment.URI)) {
+				removed++
+				continue
+			}
+			kept = append(kept, segment)
+		}
+		if len(kept) > 0 {
+			groups = append(groups, kept)
+		}
+	}
+	playlist.Groups = groups
+	return removed
+}
+
+// dropAdGroups 按主机、路径规律和已知时长识别并移除插入的广告分组
+func (f *HLSAdFilter) dropAdGroups(playlist *hlsMediaPlaylist, base *url.URL) int {
+	// 以总时长最多的主机和路径规律作为正片特征
+	hostDuration := make(map[string]float64)
+	patternDuration := make(map[string]float64)
+	mainGroup, mainDuration := 0, 0.0
+	for i, group := range playlist.Groups {
+		total := 0.0
+		for _, segment := range group {
+			host, pattern := segmentSignature(base, segment.URI)
+			hostDuration[host] += segment.Duration
+			patternDuration[pattern] += segment.Duration
+			total += segment.Duration
+		}
+		if total > mainDuration {
+			mainGroup, mainDuration = i, total
+		}
+	}
+	mainHost := maxKey(hostDuration)
+	mainPattern := maxKey(patternDuration)
+
+	removed := 0
+	groups := make([][]hlsSegment, 0, len(playlist.Groups))
+	for i, group := range playlist.Groups {
+		if i != mainGroup && f.isAdGroup(group, base, mainHost, mainPattern) {
+			removed += len(group)
+			continue
+		}
+		groups = append(groups, group)
+	}
+	playlist.Groups = groups
+	return removed
+}
+
+// isAdGroup 判断分组是否为广告
+func (f *HLSAdFilter) isAdGroup(group []hlsSegment, base *url.URL, mainHost, mainPattern string) bool {
+	total := 0.0
+	hosts := make(map[string]float64)
+	patterns := make(map[string]float64)
+	knownDurations := true
+	for _, segment := range group {
+		host, pattern := segmentSignature(base, segment.URI)
+		hosts[host] += segment.Duration
+		patterns[pattern] += segment.Duration
+		total += segment.Duration
+		if !f.isKnownDuration(segment.Duration) {
+			knownDurations = false
+		}
+	}
+
+	if f.maxAdDuration > 0 && total > f.maxAdDuration {
+		return false
+	}
+
+	switch {
+	case f.hostMismatch && maxKey(hosts) != mainHost:
+		return true
+	case f.pathMismatch && maxKey(patterns) != mainPattern:
+		return true
+	case len(f.durations) > 0 && knownDurations:
+		return true
+	}
+	return false
+}
+
+// isKnownDuration 判断分片时长是否属于配置的广告时长
+func (f *HLSAdFilter) isKnownDuration(d float64) bool {
+	for _, known := range f.durations {
+		if math.Abs(known-d) < 0.01 {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentSignature 返回分片的主机和路径规律（目录中的数字归一化）
+func segmentSignature(base *url.URL, uri string) (string, string) {
+	u, err := url.Parse(resolveSegmentURL(base, uri))
+	if err != nil {
+		return "", ""
+	}
+	return strings.ToLower(u.Host), digitsPattern.ReplaceAllString(path.Dir(u.Path), "0")
+}
+
+// resolveSegmentURL 将分片地址解析为绝对地址
+func resolveSegmentURL(base *url.URL, uri string) string {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// maxKey 返回值最大的键
+func maxKey(m map[string]float64) string {
+	best, bestValue := "", -1.0
+	for k, v := range m {
+		if v > bestValue || (v == bestValue && k < best) {
+			best, bestValue = k, v
+		}
+	}
+	return best
+}
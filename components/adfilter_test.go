@@ -0,0 +1,224 @@
+package components
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+// adPlaylist 正片分片之间插入了一段其他主机的广告
+const adPlaylist = "#EXTM3U\n" +
+	"#EXT-X-TARGETDURATION:10\n" +
+	"#EXTINF:10,\n" +
+	"/20240101/abc/0001.ts\n" +
+	"#EXTINF:10,\n" +
+	"/20240101/abc/0002.ts\n" +
+	"#EXT-X-DISCONTINUITY\n" +
+	"#EXTINF:3,\n" +
+	"https://ads.example.net/ad/1.ts\n" +
+	"#EXTINF:3,\n" +
+	"https://ads.example.net/ad/2.ts\n" +
+	"#EXT-X-DISCONTINUITY\n" +
+	"#EXTINF:10,\n" +
+	"/20240101/abc/0003.ts\n" +
+	"#EXT-X-ENDLIST\n"
+
+func newTestAdFilter(configure func(cfg *utils.Config)) *HLSAdFilter {
+	cfg := &utils.Config{}
+	cfg.AdFilter.Enabled = true
+	cfg.AdFilter.Rules = map[string]string{}
+	configure(cfg)
+	return NewHLSAdFilter(cfg)
+}
+
+func TestHLSAdFilter(t *testing.T) {
+	base, _ := url.Parse("https://video.example.com/20240101/abc/index.m3u8")
+	withoutAd := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:10\n" +
+		"#EXTINF:10,\n" +
+		"/20240101/abc/0001.ts\n" +
+		"#EXTINF:10,\n" +
+		"/20240101/abc/0002.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:10,\n" +
+		"/20240101/abc/0003.ts\n" +
+		"#EXT-X-ENDLIST\n"
+
+	tests := []struct {
+		name      string
+		configure func(cfg *utils.Config)
+		in        string
+		want      string
+		removed   int
+	}{
+		{
+			name:      "host mismatch",
+			configure: func(cfg *utils.Config) { cfg.AdFilter.HostMismatch = true },
+			in:        adPlaylist,
+			want:      withoutAd,
+			removed:   2,
+		},
+		{
+			name:      "known ad durations",
+			configure: func(cfg *utils.Config) { cfg.AdFilter.AdDurations = "3.000, 5" },
+			in:        adPlaylist,
+			want:      withoutAd,
+			removed:   2,
+		},
+		{
+			name: "group longer than max ad duration is kept",
+			configure: func(cfg *utils.Config) {
+				cfg.AdFilter.HostMismatch = true
+				cfg.AdFilter.MaxAdDuration = 5
+			},
+			in:      adPlaylist,
+			want:    adPlaylist,
+			removed: 0,
+		},
+		{
+			name: "domain rule",
+			configure: func(cfg *utils.Config) {
+				cfg.AdFilter.Rules["example.com"] = `/0002\.ts$`
+			},
+			in: adPlaylist,
+			want: "#EXTM3U\n" +
+				"#EXT-X-TARGETDURATION:10\n" +
+				"#EXTINF:10,\n" +
+				"/20240101/abc/0001.ts\n" +
+				"#EXT-X-DISCONTINUITY\n" +
+				"#EXTINF:3,\n" +
+				"https://ads.example.net/ad/1.ts\n" +
+				"#EXTINF:3,\n" +
+				"https://ads.example.net/ad/2.ts\n" +
+				"#EXT-X-DISCONTINUITY\n" +
+				"#EXTINF:10,\n" +
+				"/20240101/abc/0003.ts\n" +
+				"#EXT-X-ENDLIST\n",
+			removed: 1,
+		},
+		{
+			name:      "master playlist is unchanged",
+			configure: func(cfg *utils.Config) { cfg.AdFilter.HostMismatch = true },
+			in:        "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nhttps://ads.example.net/low.m3u8\n",
+			want:      "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nhttps://ads.example.net/low.m3u8\n",
+			removed:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := newTestAdFilter(tt.configure).Filter([]byte(tt.in), base)
+			if string(got) != tt.want || removed != tt.removed {
+				t.Errorf("Filter() removed %d (want %d)\n got:\n%s\nwant:\n%s", removed, tt.removed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHLSAdFilterDisabled(t *testing.T) {
+	if NewHLSAdFilter(&utils.Config{}) != nil {
+		t.Error("disabled ad filter should be nil")
+	}
+}
+
+func TestHLSAdFilterKeepsDecryptionState(t *testing.T) {
+	base, _ := url.Parse("https://video.example.com/20240101/abc/index.m3u8")
+
+	tests := []struct {
+		name      string
+		configure func(cfg *utils.Config)
+		in        string
+		want      string
+		removed   int
+	}{
+		{
+			// 广告之后的分片序号前移，需要写入按原序号计算的 IV
+			name:      "AES-128 without IV",
+			configure: func(cfg *utils.Config) { cfg.AdFilter.HostMismatch = true },
+			in: "#EXTM3U\n" +
+				"#EXT-X-TARGETDURATION:10\n" +
+				"#EXT-X-MEDIA-SEQUENCE:0\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/key.key\"\n" +
+				"#EXTINF:10,\n/20240101/abc/0.ts\n" +
+				"#EXTINF:10,\n/20240101/abc/1.ts\n" +
+				"#EXTINF:10,\n/20240101/abc/2.ts\n" +
+				"#EXT-X-DISCONTINUITY\n" +
+				"#EXT-X-KEY:METHOD=NONE\n" +
+				"#EXTINF:3,\nhttps://ads.example.net/ad/3.ts\n" +
+				"#EXTINF:3,\nhttps://ads.example.net/ad/4.ts\n" +
+				"#EXT-X-DISCONTINUITY\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/key.key\"\n" +
+				"#EXTINF:10,\n/20240101/abc/5.ts\n" +
+				"#EXTINF:10,\n/20240101/abc/6.ts\n" +
+				"#EXT-X-ENDLIST\n",
+			want: "#EXTM3U\n" +
+				"#EXT-X-TARGETDURATION:10\n" +
+				"#EXT-X-MEDIA-SEQUENCE:0\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/key.key\"\n" +
+				"#EXTINF:10,\n/20240101/abc/0.ts\n" +
+				"#EXTINF:10,\n/20240101/abc/1.ts\n" +
+				"#EXTINF:10,\n/20240101/abc/2.ts\n" +
+				"#EXT-X-DISCONTINUITY\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/key.key\",IV=0x00000000000000000000000000000005\n" +
+				"#EXTINF:10,\n/20240101/abc/5.ts\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/key.key\",IV=0x00000000000000000000000000000006\n" +
+				"#EXTINF:10,\n/20240101/abc/6.ts\n" +
+				"#EXT-X-ENDLIST\n",
+			removed: 2,
+		},
+		{
+			// 被移除分片上的 KEY、MAP 对之后的分片仍然生效
+			name: "key and map on removed segment",
+			configure: func(cfg *utils.Config) {
+				cfg.AdFilter.Rules["example.com"] = `/2\.m4s$`
+			},
+			in: "#EXTM3U\n" +
+				"#EXT-X-TARGETDURATION:10\n" +
+				"#EXT-X-MAP:URI=\"/init.mp4\"\n" +
+				"#EXTINF:10,\n/v/0.m4s\n" +
+				"#EXTINF:10,\n/v/1.m4s\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/k2.key\",IV=0x0000000000000000000000000000000A\n" +
+				"#EXT-X-MAP:URI=\"/init2.mp4\"\n" +
+				"#EXTINF:10,\n/v/2.m4s\n" +
+				"#EXTINF:10,\n/v/3.m4s\n" +
+				"#EXT-X-ENDLIST\n",
+			want: "#EXTM3U\n" +
+				"#EXT-X-TARGETDURATION:10\n" +
+				"#EXT-X-MAP:URI=\"/init.mp4\"\n" +
+				"#EXTINF:10,\n/v/0.m4s\n" +
+				"#EXTINF:10,\n/v/1.m4s\n" +
+				"#EXT-X-MAP:URI=\"/init2.mp4\"\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/k2.key\",IV=0x0000000000000000000000000000000A\n" +
+				"#EXTINF:10,\n/v/3.m4s\n" +
+				"#EXT-X-ENDLIST\n",
+			removed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := newTestAdFilter(tt.configure).Filter([]byte(tt.in), base)
+			if string(got) != tt.want || removed != tt.removed {
+				t.Errorf("Filter() removed %d (want %d)\n got:\n%s\nwant:\n%s", removed, tt.removed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLSAdFilterMostSpecificRule(t *testing.T) {
+	base, _ := url.Parse("https://video.example.com/20240101/abc/index.m3u8")
+	filter := newTestAdFilter(func(cfg *utils.Config) {
+		cfg.AdFilter.Rules["example.com"] = `/0001\.ts$`
+		cfg.AdFilter.Rules["video.example.com"] = `/0002\.ts$`
+		cfg.AdFilter.Rules["other.com"] = `.`
+	})
+	// 规则保存在 map 中时每次选择的规则不固定，多次执行确认结果稳定
+	for i := 0; i < 20; i++ {
+		got, removed := filter.Filter([]byte(adPlaylist), base)
+		if removed != 1 || !strings.Contains(string(got), "0001.ts") || strings.Contains(string(got), "0002.ts") {
+			t.Fatalf("should apply the video.example.com rule, removed %d:\n%s", removed, got)
+		}
+	}
+}
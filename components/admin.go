@@ -0,0 +1,44 @@
+package components
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"vastproxy-go/utils"
+)
+
+// AdminTokenHeader 管理接口携带 [admin] token 的请求头，令牌只能通过请求头传递
+const AdminTokenHeader = "X-Admin-Token"
+
+// requireAdmin 校验管理接口请求头中的 [admin] token，未设置令牌时拒绝所有请求；
+// 校验失败时写入错误响应并返回 false
+func requireAdmin(w http.ResponseWriter, r *http.Request, cfg *utils.Config) bool {
+	expected := ""
+	if cfg != nil {
+		expected = cfg.Admin.Token
+	}
+	if expected == "" {
+		writeAdminError(w, http.StatusForbidden, "未设置 [admin] token，管理接口不可用")
+		return false
+	}
+
+	token := r.Header.Get(AdminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		log.Printf("🚫 管理接口令牌错误: %s [IP:%s]", r.URL.Path, utils.GetRequestIP(r))
+		writeAdminError(w, http.StatusUnauthorized, "管理令牌错误")
+		return false
+	}
+	return true
+}
+
+// writeAdminError 返回JSON格式的错误信息
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
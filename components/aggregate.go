@@ -0,0 +1,322 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"vastproxy-go/utils"
+)
+
+const (
+	// defaultSourceTimeout 聚合搜索时单个源的默认超时
+	defaultSourceTimeout = 10 * time.Second
+	// maxSourceTimeout 聚合搜索时单个源允许的最大超时
+	maxSourceTimeout = 30 * time.Second
+)
+
+// SourceSearchStatus 单个源的搜索状态
+type SourceSearchStatus struct {
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Status    string `json:"status"` // ok / error / timeout / canceled
+	Count     int    `json:"count"`
+	PageCount int    `json:"pagecount"`
+	Latency   int64  `json:"latency"` // 毫秒
+	Error     string `json:"error,omitempty"`
+}
+
+// AggregatedSource 聚合结果中某个源提供的播放信息
+type AggregatedSource struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	VodID       string `json:"vod_id"`
+	VodRemarks  string `json:"vod_remarks"`
+	VodPlayFrom string `json:"vod_play_from"`
+	VodPlayNote string `json:"vod_play_note"`
+	VodPlayUrl  string `json:"vod_play_url"`
+
+	PlayLines []PlayLine `json:"play_lines,omitempty"`
+}
+
+// AggregatedItem 跨源合并后的视频项目
+type AggregatedItem struct {
+	VideoItem
+	Sources []AggregatedSource `json:"sources"`
+}
+
+// AggregateSearchResponse 聚合搜索响应结构
+type AggregateSearchResponse struct {
+	Success bool                 `json:"success"`
+	Message string               `json:"message"`
+	Data    []AggregatedItem     `json:"data"`
+	Count   int                  `json:"count"`
+	Sources []SourceSearchStatus `json:"sources"`
+}
+
+// sourceSearchResult 单个源的搜索结果
+type sourceSearchResult struct {
+	Index  int
+	Source VideoSource
+	Items  []VideoItem
+	Status SourceSearchStatus
+}
+
+// fanOutSearch 并发搜索多个源，每个源单独设置超时，结果完成一个发送一个，全部完成后关闭通道
+func (sc *SourcesConfig) fanOutSearch(ctx context.Context, sources []VideoSource, query SourceQuery, timeout time.Duration) <-chan sourceSearchResult {
+	results := make(chan sourceSearchResult, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(idx int, src VideoSource) {
+			defer wg.Done()
+
+			sourceCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			list, err := sc.searchSource(sourceCtx, &src, query)
+			status := SourceSearchStatus{
+				Code:    src.Code,
+				Name:    src.Name,
+				Status:  "ok",
+				Latency: time.Since(start).Milliseconds(),
+			}
+			var items []VideoItem
+			if err == nil {
+				items = list.List
+				status.Count = len(items)
+				status.PageCount = list.PageCount
+			} else {
+				status.Error = err.Error()
+				switch {
+				case ctx.Err() != nil:
+					status.Status = "canceled"
+				case errors.Is(sourceCtx.Err(), context.DeadlineExceeded):
+					status.Status = "timeout"
+				default:
+					status.Status = "error"
+				}
+			}
+
+			results <- sourceSearchResult{
+				Index:  idx,
+				Source: src,
+				Items:  items,
+				Status: status,
+			}
+		}(i, source)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// mergeSearchResults 按 标准化片名+年份 合并各源结果，保持源的配置顺序
+func mergeSearchResults(results []sourceSearchResult) []AggregatedItem {
+	ordered := make([]sourceSearchResult, len(results))
+	copy(ordered, results)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Index < ordered[j].Index
+	})
+
+	merged := []AggregatedItem{}
+	index := make(map[string]int)
+	for _, result := range ordered {
+		for _, item := range result.Items {
+			ref := AggregatedSource{
+				Code:        result.Source.Code,
+				Name:        result.Source.Name,
+				VodID:       item.VodID,
+				VodRemarks:  item.VodRemarks,
+				VodPlayFrom: item.VodPlayFrom,
+				VodPlayNote: item.VodPlayNote,
+				VodPlayUrl:  item.VodPlayUrl,
+				PlayLines:   item.PlayLines,
+			}
+
+			key := mergeKey(item)
+			if pos, ok := index[key]; ok {
+				fillEmptyFields(&merged[pos].VideoItem, item)
+				merged[pos].Sources = append(merged[pos].Sources, ref)
+				continue
+			}
+
+			index[key] = len(merged)
+			merged = append(merged, AggregatedItem{
+				VideoItem: item,
+				Sources:   []AggregatedSource{ref},
+			})
+		}
+	}
+	return merged
+}
+
+// mergeKey 生成合并使用的键
+func mergeKey(item VideoItem) string {
+	return normalizeTitle(item.VodName) + "|" + strings.TrimSpace(item.VodYear)
+}
+
+// normalizeTitle 标准化片名：去除空白、标点和符号并转为小写
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range title {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// fillEmptyFields 用其他源的数据补全空字段
+func fillEmptyFields(dst *VideoItem, src VideoItem) {
+	fill := func(d *string, s string) {
+		if *d == "" {
+			*d = s
+		}
+	}
+	fill(&dst.VodSub, src.VodSub)
+	fill(&dst.VodEn, src.VodEn)
+	fill(&dst.VodPic, src.VodPic)
+	fill(&dst.TypeName, src.TypeName)
+	fill(&dst.VodScore, src.VodScore)
+	fill(&dst.VodContent, src.VodContent)
+	fill(&dst.VodActor, src.VodActor)
+	fill(&dst.VodDirector, src.VodDirector)
+	fill(&dst.VodArea, src.VodArea)
+	fill(&dst.VodLang, src.VodLang)
+	fill(&dst.VodDoubanID, src.VodDoubanID)
+	fill(&dst.VodPubdate, src.VodPubdate)
+}
+
+// selectSources 根据逗号分隔的源代码选择源，为空时返回所有启用的源；已禁用的源不会被选中
+func (sc *SourcesConfig) selectSources(codes string) []VideoSource {
+	if strings.TrimSpace(codes) == "" {
+		return sc.GetEnabledSources()
+	}
+	var selected []VideoSource
+	for _, code := range strings.Split(codes, ",") {
+		if source := sc.GetSourceByCode(strings.TrimSpace(code)); source != nil && source.Enabled {
+			selected = append(selected, *source)
+		}
+	}
+	return selected
+}
+
+// parseSourceTimeout 解析单个源的超时参数（秒）
+func parseSourceTimeout(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return defaultSourceTimeout
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxSourceTimeout {
+		return maxSourceTimeout
+	}
+	return timeout
+}
+
+// HandleAggregateSearchAPI 处理 /api/aggregate_search 接口
+func (sc *SourcesConfig) HandleAggregateSearchAPI(w http.ResponseWriter, r *http.Request) {
+	// 设置CORS头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理OPTIONS请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// 只允许GET请求
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Method not allowed",
+			"data":    []AggregatedItem{},
+		})
+		return
+	}
+
+	// 获取查询参数
+	keyword := r.URL.Query().Get("keyword")
+	page := r.URL.Query().Get("page")
+	isLatest := r.URL.Query().Get("latest") == "true"
+	hours := r.URL.Query().Get("hours")
+	timeout := parseSourceTimeout(r.URL.Query().Get("timeout"))
+
+	if !isPositiveInt(hours) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid hours parameter",
+			"data":    []AggregatedItem{},
+		})
+		return
+	}
+
+	if !isLatest && keyword == "" && hours == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Missing keyword parameter",
+			"data":    []AggregatedItem{},
+		})
+		return
+	}
+	if isLatest {
+		keyword = ""
+	}
+
+	sources := sc.selectSources(r.URL.Query().Get("sources"))
+	if len(sources) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "No available sources",
+			"data":    []AggregatedItem{},
+		})
+		return
+	}
+
+	// 并发搜索并收集结果
+	var results []sourceSearchResult
+	statuses := make([]SourceSearchStatus, len(sources))
+	for result := range sc.fanOutSearch(r.Context(), sources, SourceQuery{Keyword: keyword, Page: page, Hours: hours}, timeout) {
+		results = append(results, result)
+		statuses[result.Index] = result.Status
+		if result.Status.Status != "ok" {
+			log.Printf("⚠️ 聚合搜索源 %s 失败: %s [IP:%s]", result.Source.Code, result.Status.Error, utils.GetRequestIP(r))
+		}
+	}
+
+	merged := mergeSearchResults(results)
+	response := AggregateSearchResponse{
+		Success: true,
+		Message: "搜索成功",
+		Data:    merged,
+		Count:   len(merged),
+		Sources: statuses,
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("✅ /api/aggregate_search 请求 (%d 个源, %d 个结果) [IP:%s]", len(sources), len(merged), utils.GetRequestIP(r))
+}
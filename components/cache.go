@@ -0,0 +1,467 @@
+package components
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// maxCacheEntrySize 单个缓存对象的最大字节数，超过的响应（如完整视频文件）不缓存
+const maxCacheEntrySize = 64 << 20
+
+// 缓存文件扩展名
+const (
+	cacheDataExt = ".data"
+	cacheMetaExt = ".json"
+	cacheTempExt = ".tmp"
+)
+
+// segmentExtensions 视为不可变分片的地址扩展名
+var segmentExtensions = map[string]bool{
+	".ts":  true,
+	".m4s": true,
+	".m4a": true,
+	".m4v": true,
+	".mp4": true,
+	".aac": true,
+	".key": true,
+}
+
+// cacheMeta 缓存对象的元数据，与数据文件一起保存在磁盘上
+type cacheMeta struct {
+	URL         string    `json:"url"`
+	FinalURL    string    `json:"final_url"` // 重定向后的地址，播放列表以此解析相对地址
+	ContentType string    `json:"content_type"`
+	Playlist    bool      `json:"playlist"`
+	Size        int64     `json:"size"`
+	Expires     time.Time `json:"expires"`
+}
+
+// cacheEntry LRU 链表中的缓存项
+type cacheEntry struct {
+	key  string
+	meta cacheMeta
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Enabled   bool    `json:"enabled"`
+	Dir       string  `json:"dir,omitempty"`
+	Entries   int     `json:"entries"`
+	Size      int64   `json:"size"`
+	MaxSize   int64   `json:"max_size"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Stores    int64   `json:"stores"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// ProxyCache /proxy 的磁盘缓存，按规范化后的地址缓存分片和播放列表，超过容量时按 LRU 淘汰
+type ProxyCache struct {
+	dir             string
+	maxSize         int64
+	livePlaylistTTL time.Duration
+	vodPlaylistTTL  time.Duration
+	segmentTTL      time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // 队首为最近访问
+	entries map[string]*list.Element
+
+	hits, misses, stores, evictions int64
+}
+
+// NewProxyCache 根据 [cache] 配置创建缓存并加载磁盘上已有的缓存，未启用时返回 nil
+func NewProxyCache(cfg *utils.Config) (*ProxyCache, error) {
+	if cfg == nil || !cfg.Cache.Enabled {
+		return nil, nil
+	}
+
+	c := &ProxyCache{
+		dir:             cfg.Cache.Dir,
+		maxSize:         int64(cfg.Cache.MaxSizeMB) << 20,
+		livePlaylistTTL: time.Duration(cfg.Cache.LivePlaylistTTL) * time.Second,
+		vodPlaylistTTL:  time.Duration(cfg.Cache.VODPlaylistTTL) * time.Second,
+		segmentTTL:      time.Duration(cfg.Cache.SegmentTTL) * time.Second,
+		lru:             list.New(),
+		entries:         make(map[string]*list.Element),
+	}
+	if c.dir == "" {
+		c.dir = "cache"
+	}
+	if c.maxSize <= 0 {
+		c.maxSize = 1024 << 20
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, err
+	}
+	c.load()
+	return c, nil
+}
+
+// load 扫描缓存目录重建索引，按数据文件的修改时间恢复访问顺序
+func (c *ProxyCache) load() {
+	type loaded struct {
+		entry   *cacheEntry
+		modTime time.Time
+	}
+	var items []loaded
+	now := time.Now()
+
+	filepath.Walk(c.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(p) {
+		case cacheTempExt:
+			// 上次未完成的写入
+			os.Remove(p)
+		case cacheMetaExt:
+			key := strings.TrimSuffix(filepath.Base(p), cacheMetaExt)
+			dataPath := strings.TrimSuffix(p, cacheMetaExt) + cacheDataExt
+			var meta cacheMeta
+			data, readErr := os.ReadFile(p)
+			dataInfo, statErr := os.Stat(dataPath)
+			if readErr != nil || statErr != nil || json.Unmarshal(data, &meta) != nil ||
+				dataInfo.Size() != meta.Size || now.After(meta.Expires) {
+				os.Remove(p)
+				os.Remove(dataPath)
+				return nil
+			}
+			items = append(items, loaded{&cacheEntry{key: key, meta: meta}, dataInfo.ModTime()})
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool { return items[i].modTime.Before(items[j].modTime) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, item := range items {
+		c.entries[item.entry.key] = c.lru.PushFront(item.entry)
+		c.size += item.entry.meta.Size
+	}
+	c.evictLocked()
+}
+
+// Get 查找缓存，命中时返回元数据和数据文件路径
+func (c *ProxyCache) Get(u *url.URL) (*cacheMeta, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+	key := cacheKey(u)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, "", false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.meta.Expires) {
+		c.removeLocked(elem)
+		c.misses++
+		return nil, "", false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+	dataPath := c.pathFor(key, cacheDataExt)
+	// 记录访问时间，重启后按此恢复 LRU 顺序
+	now := time.Now()
+	os.Chtimes(dataPath, now, now)
+	meta := entry.meta
+	return &meta, dataPath, true
+}
+
+// StorePlaylist 缓存原始播放列表；主播放列表和直播播放列表使用短有效期，点播播放列表使用较长有效期
+func (c *ProxyCache) StorePlaylist(u *url.URL, resp *http.Response, data []byte) {
+	if c == nil {
+		return
+	}
+	ttl := c.livePlaylistTTL
+	if bytes.Contains(data, []byte("#EXT-X-ENDLIST")) {
+		ttl = c.vodPlaylistTTL
+	}
+	c.storeBytes(u, resp, data, true, ttl)
+}
+
+// StoreSegment 缓存已完整读取的分片，用于预取
+func (c *ProxyCache) StoreSegment(u *url.URL, resp *http.Response, data []byte) {
+	if c == nil || !isCacheableSegment(resp) {
+		return
+	}
+	c.storeBytes(u, resp, data, false, c.segmentTTL)
+}
+
+// Contains 判断地址是否已有未过期的缓存，不计入命中统计
+func (c *ProxyCache) Contains(u *url.URL) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[cacheKey(u)]
+	return ok && time.Now().Before(elem.Value.(*cacheEntry).meta.Expires)
+}
+
+// storeBytes 把完整内容写入缓存
+func (c *ProxyCache) storeBytes(u *url.URL, resp *http.Response, data []byte, playlist bool, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	w := c.newWriter(u, resp, playlist, ttl)
+	if w == nil {
+		return
+	}
+	w.Write(data)
+	w.commit()
+}
+
+// TeeSegment 对可缓存的分片响应返回一个 Reader，读取的内容同时写入缓存，完整读取后生效；
+// 调用方需在结束后调用返回的函数，未读完的内容会被丢弃
+func (c *ProxyCache) TeeSegment(u *url.URL, r *http.Request, resp *http.Response, body io.Reader) (io.Reader, func()) {
+	noop := func() {}
+	if c == nil || c.segmentTTL <= 0 || r.Header.Get("Range") != "" || !isCacheableSegment(resp) {
+		return body, noop
+	}
+	if resp.ContentLength > maxCacheEntrySize || resp.ContentLength > c.maxSize {
+		return body, noop
+	}
+	w := c.newWriter(u, resp, false, c.segmentTTL)
+	if w == nil {
+		return body, noop
+	}
+	return &cacheTeeReader{r: body, w: w, expected: resp.ContentLength}, func() { w.Close() }
+}
+
+// Stats 返回缓存统计
+func (c *ProxyCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := CacheStats{
+		Enabled:   true,
+		Dir:       c.dir,
+		Entries:   c.lru.Len(),
+		Size:      c.size,
+		MaxSize:   c.maxSize,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Stores:    c.stores,
+		Evictions: c.evictions,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// newWriter 创建写入临时文件的缓存写入器
+func (c *ProxyCache) newWriter(u *url.URL, resp *http.Response, playlist bool, ttl time.Duration) *cacheWriter {
+	key := cacheKey(u)
+	if err := os.MkdirAll(filepath.Dir(c.pathFor(key, cacheDataExt)), 0755); err != nil {
+		return nil
+	}
+	file, err := os.CreateTemp(filepath.Dir(c.pathFor(key, cacheDataExt)), key+"-*"+cacheTempExt)
+	if err != nil {
+		log.Printf("⚠️ 创建缓存文件失败: %v", err)
+		return nil
+	}
+	return &cacheWriter{
+		cache: c,
+		key:   key,
+		file:  file,
+		meta: cacheMeta{
+			URL:         u.String(),
+			FinalURL:    resp.Request.URL.String(),
+			ContentType: resp.Header.Get("Content-Type"),
+			Playlist:    playlist,
+			Expires:     time.Now().Add(ttl),
+		},
+	}
+}
+
+// commit 把写入完成的临时文件加入缓存
+func (c *ProxyCache) commit(w *cacheWriter) error {
+	dataPath := c.pathFor(w.key, cacheDataExt)
+	metaPath := c.pathFor(w.key, cacheMetaExt)
+	metaData, err := json.Marshal(w.meta)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[w.key]; ok {
+		c.removeLocked(elem)
+	}
+	if err := os.Rename(w.file.Name(), dataPath); err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
+		os.Remove(dataPath)
+		return err
+	}
+	c.entries[w.key] = c.lru.PushFront(&cacheEntry{key: w.key, meta: w.meta})
+	c.size += w.meta.Size
+	c.stores++
+	c.evictLocked()
+	return nil
+}
+
+// evictLocked 淘汰最久未访问的缓存直到总大小不超过上限
+func (c *ProxyCache) evictLocked() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.removeLocked(c.lru.Back())
+		c.evictions++
+	}
+}
+
+// removeLocked 删除缓存项及其文件
+func (c *ProxyCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.meta.Size
+	os.Remove(c.pathFor(entry.key, cacheDataExt))
+	os.Remove(c.pathFor(entry.key, cacheMetaExt))
+}
+
+// pathFor 缓存文件路径，按键的前两位分目录存放
+func (c *ProxyCache) pathFor(key, ext string) string {
+	return filepath.Join(c.dir, key[:2], key+ext)
+}
+
+// cacheWriter 缓存写入器，内容先写入临时文件，commit 后才对外可见
+type cacheWriter struct {
+	cache  *ProxyCache
+	key    string
+	file   *os.File
+	meta   cacheMeta
+	done   bool
+	failed bool
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.failed {
+		return len(p), nil
+	}
+	if w.meta.Size+int64(len(p)) > maxCacheEntrySize || w.meta.Size+int64(len(p)) > w.cache.maxSize {
+		w.failed = true
+		return len(p), nil
+	}
+	n, err := w.file.Write(p)
+	w.meta.Size += int64(n)
+	if err != nil {
+		w.failed = true
+	}
+	return len(p), nil
+}
+
+// commit 写入完成，加入缓存
+func (w *cacheWriter) commit() {
+	if w.done {
+		return
+	}
+	w.done = true
+	closeErr := w.file.Close()
+	if w.failed || closeErr != nil {
+		os.Remove(w.file.Name())
+		return
+	}
+	if err := w.cache.commit(w); err != nil {
+		log.Printf("⚠️ 写入缓存失败: %v", err)
+		os.Remove(w.file.Name())
+	}
+}
+
+// Close 放弃未完成的写入
+func (w *cacheWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.file.Close()
+	return os.Remove(w.file.Name())
+}
+
+// cacheTeeReader 读取上游响应的同时写入缓存，读到结尾且长度完整时提交
+type cacheTeeReader struct {
+	r        io.Reader
+	w        *cacheWriter
+	expected int64
+}
+
+func (t *cacheTeeReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n])
+	}
+	if errors.Is(err, io.EOF) {
+		if t.expected < 0 || t.w.meta.Size == t.expected {
+			t.w.commit()
+		} else {
+			t.w.Close()
+		}
+	}
+	return n, err
+}
+
+// isCacheableSegment 根据 Content-Type 或地址扩展名判断是否为可长期缓存的分片
+func isCacheableSegment(resp *http.Response) bool {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if strings.Contains(contentType, "mp2t") || strings.Contains(contentType, "iso.segment") {
+		return true
+	}
+	return segmentExtensions[strings.ToLower(path.Ext(resp.Request.URL.Path))]
+}
+
+// cacheKey 规范化地址（协议和主机小写、去除默认端口和片段、查询参数排序）后计算缓存键
+func cacheKey(u *url.URL) string {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (normalized.Scheme == "http" && port == "80") || (normalized.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	normalized.Host = host
+	normalized.User = nil
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+	normalized.RawQuery = u.Query().Encode()
+	normalized.ForceQuery = false
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+
+	sum := sha256.Sum256([]byte(normalized.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,10 +3,14 @@ package components
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"vastproxy-go/utils"
@@ -28,6 +32,48 @@ type DoubanSubjectsResponse struct {
 	Subjects []DoubanSubject `json:"subjects"`
 }
 
+// DoubanSearchItem 豆瓣搜索结果项（subject_suggest 不返回评分，需要评分时请求详情）
+type DoubanSearchItem struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	SubTitle string `json:"sub_title"`
+	Year     string `json:"year"`
+	Type     string `json:"type"`
+	Episode  string `json:"episode"`
+	Cover    string `json:"cover"`
+	URL      string `json:"url"`
+}
+
+// doubanSearchPageCount 豆瓣搜索的页数，subject_suggest 接口不支持分页
+const doubanSearchPageCount = 1
+
+// DoubanSearchResult 豆瓣搜索结果
+type DoubanSearchResult struct {
+	List      []DoubanSearchItem `json:"list"`
+	Page      int                `json:"page"`
+	PageCount int                `json:"pagecount"`
+	Total     int                `json:"total"`
+	HasMore   bool               `json:"has_more"`
+}
+
+// DoubanDetail 豆瓣条目详情
+type DoubanDetail struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Year          string   `json:"year"`
+	Cover         string   `json:"cover"`
+	URL           string   `json:"url"`
+	Rate          string   `json:"rate"`
+	RatingCount   int      `json:"rating_count"`
+	Summary       string   `json:"summary"`
+	Directors     []string `json:"directors"`
+	Cast          []string `json:"cast"`
+	Genres        []string `json:"genres"`
+	Duration      string   `json:"duration"`
+	DatePublished string   `json:"date_published"`
+	IMDbID        string   `json:"imdb_id"`
+}
+
 // DoubanHandler 处理豆瓣API请求
 func DoubanHandler(w http.ResponseWriter, r *http.Request, globalConfig interface{}) {
 	// 设置响应头
@@ -56,6 +102,10 @@ func DoubanHandler(w http.ResponseWriter, r *http.Request, globalConfig interfac
 		handleDoubanTags(w, r)
 	case "subjects":
 		handleDoubanSubjects(w, r)
+	case "search":
+		handleDoubanSearch(w, r)
+	case "detail":
+		handleDoubanDetail(w, r)
 	default:
 		// 返回 API 使用说明
 		apiInfo := map[string]interface{}{
@@ -70,12 +120,22 @@ func DoubanHandler(w http.ResponseWriter, r *http.Request, globalConfig interfac
 					"url": "/douban?action=subjects&type=movie&tag=热门&page_limit=16&page_start=0",
 					"参数":  "type: movie 或 tv, tag: 标签名, page_limit: 每页数量, page_start: 起始位置",
 				},
+				"搜索": map[string]string{
+					"url": "/douban?action=search&wd=复仇者联盟&page=1",
+					"参数":  "wd: 关键词, page: 页码",
+				},
+				"获取详情": map[string]string{
+					"url": "/douban?action=detail&id=1432146",
+					"参数":  "id: 豆瓣条目ID",
+				},
 			},
 			"example": map[string]string{
 				"获取电影标签":  "/douban?action=tags&type=movie",
 				"获取电视剧标签": "/douban?action=tags&type=tv",
 				"获取热门电影":  "/douban?action=subjects&type=movie&tag=热门&page_limit=16&page_start=0",
 				"获取美剧推荐":  "/douban?action=subjects&type=tv&tag=美剧&page_limit=16&page_start=0",
+				"搜索":      "/douban?action=search&wd=复仇者联盟",
+				"获取详情":    "/douban?action=detail&id=1432146",
 			},
 		}
 
@@ -168,14 +228,12 @@ func fetchDoubanData(targetURL string) ([]byte, error) {
 	}
 
 	// 设置请求头
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+	req.Header.Set("User-Agent", UserAgent())
 	req.Header.Set("Referer", "https://movie.douban.com/")
 	req.Header.Set("Accept", "application/json, text/plain, */*")
 
-	// 创建客户端
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	// 使用共享连接池的客户端
+	client := NewHTTPClient("", 30*time.Second)
 
 	// 发送请求
 	resp, err := client.Do(req)
@@ -197,3 +255,232 @@ func fetchDoubanData(targetURL string) ([]byte, error) {
 
 	return body, nil
 }
+
+// handleDoubanSearch 处理豆瓣搜索请求
+func handleDoubanSearch(w http.ResponseWriter, r *http.Request) {
+	keyword := strings.TrimSpace(r.URL.Query().Get("wd"))
+	if keyword == "" {
+		http.Error(w, "Missing wd parameter", http.StatusBadRequest)
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	if page > doubanSearchPageCount {
+		http.Error(w, fmt.Sprintf("Douban search only supports page 1-%d", doubanSearchPageCount), http.StatusBadRequest)
+		return
+	}
+
+	result, err := searchDouban(keyword)
+	if err != nil {
+		log.Printf("❌ 豆瓣搜索失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		http.Error(w, "Failed to search douban", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    result,
+	})
+	log.Printf("✅ 返回豆瓣搜索数据 (关键词: %s, 数量: %d) [IP:%s]", keyword, len(result.List), utils.GetRequestIP(r))
+}
+
+// handleDoubanDetail 处理豆瓣详情请求
+func handleDoubanDetail(w http.ResponseWriter, r *http.Request) {
+	subjectID := strings.TrimSpace(r.URL.Query().Get("id"))
+	if subjectID == "" || !doubanIDPattern.MatchString(subjectID) {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := fetchDoubanDetail(subjectID)
+	if err != nil {
+		log.Printf("❌ 获取豆瓣详情失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		http.Error(w, "Failed to fetch douban detail", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    detail,
+	})
+	log.Printf("✅ 返回豆瓣详情数据 (%s %s) [IP:%s]", subjectID, detail.Title, utils.GetRequestIP(r))
+}
+
+// doubanSuggestItem 豆瓣 subject_suggest 接口返回项
+type doubanSuggestItem struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	SubTitle string `json:"sub_title"`
+	Year     string `json:"year"`
+	Type     string `json:"type"`
+	Episode  string `json:"episode"`
+	Img      string `json:"img"`
+	URL      string `json:"url"`
+}
+
+// searchDouban 通过豆瓣 subject_suggest 接口搜索影视条目
+// 该接口不支持分页，只有一页结果
+func searchDouban(keyword string) (*DoubanSearchResult, error) {
+	doubanURL := fmt.Sprintf("https://movie.douban.com/j/subject_suggest?q=%s", url.QueryEscape(keyword))
+	data, err := fetchDoubanData(doubanURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseDoubanSuggest(data)
+}
+
+// parseDoubanSuggest 将 subject_suggest 接口的返回转换为搜索结果，list 始终不为 null
+func parseDoubanSuggest(data []byte) (*DoubanSearchResult, error) {
+	result := &DoubanSearchResult{List: []DoubanSearchItem{}, Page: 1, PageCount: doubanSearchPageCount}
+
+	var suggestions []doubanSuggestItem
+	if err := json.Unmarshal(data, &suggestions); err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %v", err)
+	}
+
+	for _, s := range suggestions {
+		// 只保留影视条目，过滤影人等其他类型
+		if s.Type != "" && s.Type != "movie" && s.Type != "tv" {
+			continue
+		}
+		result.List = append(result.List, DoubanSearchItem{
+			ID:       s.ID,
+			Title:    s.Title,
+			SubTitle: s.SubTitle,
+			Year:     s.Year,
+			Type:     s.Type,
+			Episode:  s.Episode,
+			Cover:    s.Img,
+			URL:      fmt.Sprintf("https://movie.douban.com/subject/%s/", s.ID),
+		})
+	}
+	result.Total = len(result.List)
+	return result, nil
+}
+
+var (
+	doubanIDPattern      = regexp.MustCompile(`^\d+$`)
+	doubanLDJSONPattern  = regexp.MustCompile(`(?s)<script type="application/ld\+json">(.*?)</script>`)
+	doubanYearPattern    = regexp.MustCompile(`<span class="year">\((\d{4})\)</span>`)
+	doubanIMDbPattern    = regexp.MustCompile(`IMDb:</span>\s*(tt\d+)`)
+	doubanSummaryPattern = regexp.MustCompile(`(?s)<span property="v:summary"[^>]*>(.*?)</span>`)
+	doubanFullSummary    = regexp.MustCompile(`(?s)<span class="all hidden">(.*?)</span>`)
+	htmlTagPattern       = regexp.MustCompile(`<[^>]+>`)
+)
+
+// doubanLDJSON 豆瓣条目页面中的 ld+json 数据
+type doubanLDJSON struct {
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Director []struct {
+		Name string `json:"name"`
+	} `json:"director"`
+	Actor []struct {
+		Name string `json:"name"`
+	} `json:"actor"`
+	DatePublished   string   `json:"datePublished"`
+	Genre           []string `json:"genre"`
+	Duration        string   `json:"duration"`
+	Description     string   `json:"description"`
+	AggregateRating struct {
+		RatingCount string `json:"ratingCount"`
+		RatingValue string `json:"ratingValue"`
+	} `json:"aggregateRating"`
+}
+
+// fetchDoubanDetail 获取豆瓣条目页面并解析详情
+func fetchDoubanDetail(subjectID string) (*DoubanDetail, error) {
+	pageURL := fmt.Sprintf("https://movie.douban.com/subject/%s/", subjectID)
+	data, err := fetchDoubanData(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseDoubanDetail(subjectID, pageURL, string(data))
+}
+
+// parseDoubanDetail 从豆瓣条目页面HTML中提取详情
+func parseDoubanDetail(subjectID, pageURL, page string) (*DoubanDetail, error) {
+	match := doubanLDJSONPattern.FindStringSubmatch(page)
+	if match == nil {
+		return nil, fmt.Errorf("页面中未找到条目数据")
+	}
+
+	// 豆瓣的 ld+json 中可能包含未转义的换行符
+	raw := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, match[1])
+
+	var ld doubanLDJSON
+	if err := json.Unmarshal([]byte(raw), &ld); err != nil {
+		return nil, fmt.Errorf("解析条目数据失败: %v", err)
+	}
+
+	detail := &DoubanDetail{
+		ID:            subjectID,
+		Title:         html.UnescapeString(ld.Name),
+		Cover:         ld.Image,
+		URL:           pageURL,
+		Rate:          ld.AggregateRating.RatingValue,
+		Summary:       html.UnescapeString(strings.TrimSpace(ld.Description)),
+		Directors:     []string{},
+		Cast:          []string{},
+		Genres:        []string{},
+		Duration:      ld.Duration,
+		DatePublished: ld.DatePublished,
+	}
+	detail.RatingCount, _ = strconv.Atoi(ld.AggregateRating.RatingCount)
+	for _, d := range ld.Director {
+		detail.Directors = append(detail.Directors, html.UnescapeString(d.Name))
+	}
+	for _, a := range ld.Actor {
+		detail.Cast = append(detail.Cast, html.UnescapeString(a.Name))
+	}
+	if ld.Genre != nil {
+		detail.Genres = ld.Genre
+	}
+
+	if m := doubanYearPattern.FindStringSubmatch(page); m != nil {
+		detail.Year = m[1]
+	} else if len(ld.DatePublished) >= 4 {
+		detail.Year = ld.DatePublished[:4]
+	}
+	if m := doubanIMDbPattern.FindStringSubmatch(page); m != nil {
+		detail.IMDbID = m[1]
+	}
+
+	// ld+json 中的简介会被截断，优先使用页面中的完整简介
+	summary := doubanFullSummary.FindStringSubmatch(page)
+	if summary == nil {
+		summary = doubanSummaryPattern.FindStringSubmatch(page)
+	}
+	if summary != nil {
+		if text := cleanDoubanText(summary[1]); text != "" {
+			detail.Summary = text
+		}
+	}
+
+	return detail, nil
+}
+
+// cleanDoubanText 去除HTML标签并整理空白
+func cleanDoubanText(s string) string {
+	s = strings.ReplaceAll(s, "<br>", "\n")
+	s = strings.ReplaceAll(s, "<br />", "\n")
+	s = html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,146 @@
+package components
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const doubanSuggestFixture = `[
+	{"episode":"","img":"https://img1.doubanio.com/view/photo/s_ratio_poster/public/p1.jpg","title":"霸王别姬","url":"https://movie.douban.com/subject/1291546/?suggest=%E9%9C%B8","type":"movie","year":"1993","sub_title":"霸王别姬","id":"1291546"},
+	{"episode":"40","img":"https://img2.doubanio.com/view/photo/s_ratio_poster/public/p2.jpg","title":"霸王别姬","url":"https://movie.douban.com/subject/2/","type":"tv","year":"2023","sub_title":"","id":"2"},
+	{"img":"https://img3.doubanio.com/view/celebrity/raw/public/p3.jpg","title":"张国荣","url":"https://movie.douban.com/celebrity/1003494/","type":"celebrity","id":"1003494"}
+]`
+
+func TestParseDoubanSuggest(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []DoubanSearchItem
+	}{
+		{
+			name: "movies and tv only",
+			data: doubanSuggestFixture,
+			want: []DoubanSearchItem{
+				{ID: "1291546", Title: "霸王别姬", SubTitle: "霸王别姬", Year: "1993", Type: "movie", Cover: "https://img1.doubanio.com/view/photo/s_ratio_poster/public/p1.jpg", URL: "https://movie.douban.com/subject/1291546/"},
+				{ID: "2", Title: "霸王别姬", Year: "2023", Type: "tv", Episode: "40", Cover: "https://img2.doubanio.com/view/photo/s_ratio_poster/public/p2.jpg", URL: "https://movie.douban.com/subject/2/"},
+			},
+		},
+		{
+			name: "empty",
+			data: `[]`,
+			want: []DoubanSearchItem{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseDoubanSuggest([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(result.List, tt.want) {
+				t.Fatalf("list = %+v\nwant %+v", result.List, tt.want)
+			}
+			if result.Page != 1 || result.PageCount != doubanSearchPageCount || result.Total != len(tt.want) || result.HasMore {
+				t.Errorf("分页信息错误: %+v", result)
+			}
+
+			// 前端读取 data.data.list，没有结果时也必须是数组
+			body, _ := json.Marshal(map[string]interface{}{"success": true, "data": result})
+			var resp struct {
+				Data struct {
+					List []map[string]interface{} `json:"list"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil || resp.Data.List == nil || len(resp.Data.List) != len(tt.want) {
+				t.Errorf("响应中的 data.list 无效: %s", body)
+			}
+		})
+	}
+
+	if _, err := parseDoubanSuggest([]byte(`{"error":"rate limited"}`)); err == nil {
+		t.Errorf("非数组返回应报错")
+	}
+}
+
+const doubanDetailFixture = `<html><head>
+<script type="application/ld+json">
+{
+  "@context": "http://schema.org",
+  "name": "霸王别姬 Farewell My Concubine",
+  "url": "/subject/1291546/",
+  "image": "https://img3.doubanio.com/view/photo/s_ratio_poster/public/p2561716440.webp",
+  "director": [{"@type": "Person", "url": "/celebrity/1023040/", "name": "陈凯歌 Kaige Chen"}],
+  "actor": [
+    {"@type": "Person", "url": "/celebrity/1003494/", "name": "张国荣 Leslie Cheung"},
+    {"@type": "Person", "url": "/celebrity/1050265/", "name": "张丰毅 Fengyi Zhang"}
+  ],
+  "datePublished": "1993-07-26",
+  "genre": ["剧情", "爱情", "同性"],
+  "duration": "PT2H51M",
+  "description": "段小楼（张丰毅）与程蝶衣（张国荣）是一对打小一起长大的师兄弟，
+两人一个演生，一个饰旦……",
+  "@type": "Movie",
+  "aggregateRating": {"@type": "AggregateRating", "ratingCount": "2182345", "bestRating": "10", "worstRating": "2", "ratingValue": "9.6"}
+}
+</script>
+</head><body>
+<h1><span property="v:itemreviewed">霸王别姬 Farewell My Concubine</span> <span class="year">(1993)</span></h1>
+<span class="pl">IMDb:</span> tt0106332<br>
+<span property="v:summary" class="">段小楼与程蝶衣……</span>
+<span class="all hidden">
+        段小楼（张丰毅）与程蝶衣（张国荣）是一对打小一起长大的师兄弟。<br />
+        两人一个演生，一个饰旦，一向配合天衣无缝，尤其一出《霸王别姬》&amp;更是誉满京城。
+</span>
+</body></html>`
+
+func TestParseDoubanDetail(t *testing.T) {
+	const pageURL = "https://movie.douban.com/subject/1291546/"
+	detail, err := parseDoubanDetail("1291546", pageURL, doubanDetailFixture)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := &DoubanDetail{
+		ID:            "1291546",
+		Title:         "霸王别姬 Farewell My Concubine",
+		Year:          "1993",
+		Cover:         "https://img3.doubanio.com/view/photo/s_ratio_poster/public/p2561716440.webp",
+		URL:           pageURL,
+		Rate:          "9.6",
+		RatingCount:   2182345,
+		Summary:       "段小楼（张丰毅）与程蝶衣（张国荣）是一对打小一起长大的师兄弟。\n两人一个演生，一个饰旦，一向配合天衣无缝，尤其一出《霸王别姬》&更是誉满京城。",
+		Directors:     []string{"陈凯歌 Kaige Chen"},
+		Cast:          []string{"张国荣 Leslie Cheung", "张丰毅 Fengyi Zhang"},
+		Genres:        []string{"剧情", "爱情", "同性"},
+		Duration:      "PT2H51M",
+		DatePublished: "1993-07-26",
+		IMDbID:        "tt0106332",
+	}
+	if !reflect.DeepEqual(detail, want) {
+		t.Fatalf("detail = %+v\nwant %+v", detail, want)
+	}
+}
+
+func TestParseDoubanDetailFallbacks(t *testing.T) {
+	// 没有年份、IMDb 和完整简介时使用 ld+json 中的数据，列表字段为空数组
+	page := `<script type="application/ld+json">{"name":"Tom &amp; Jerry","datePublished":"2021-02-26","description":" 简介 "}</script>`
+	detail, err := parseDoubanDetail("1", "https://movie.douban.com/subject/1/", page)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if detail.Title != "Tom & Jerry" || detail.Year != "2021" || detail.Summary != "简介" || detail.IMDbID != "" {
+		t.Errorf("detail = %+v", detail)
+	}
+	if detail.Directors == nil || detail.Cast == nil || detail.Genres == nil {
+		t.Errorf("列表字段应为空数组: %+v", detail)
+	}
+
+	for name, page := range map[string]string{
+		"missing ld+json": `<html><body>检测到有异常请求</body></html>`,
+		"invalid ld+json": `<script type="application/ld+json">{"name":</script>`,
+	} {
+		if _, err := parseDoubanDetail("1", "", page); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// 下载参数
+const (
+	defaultDownloadConcurrency = 4
+	maxDownloadConcurrency     = 16
+	maxPlaylistDepth           = 3 // 主播放列表最多嵌套层数
+	segmentRetries             = 3
+)
+
+// hlsAttrPattern 匹配标签属性 KEY=VALUE 或 KEY="VALUE"
+var hlsAttrPattern = regexp.MustCompile(`([A-Z0-9-]+)=("[^"]*"|[^,]*)`)
+
+// invalidFilenameChars 文件名中不允许的字符
+var invalidFilenameChars = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f]`)
+
+// hlsKey #EXT-X-KEY 声明的加密信息
+type hlsKey struct {
+	Method string
+	URI    string
+	IV     []byte // 为空时使用分片序号
+}
+
+// downloadSegment 待下载的分片
+type downloadSegment struct {
+	URL      string
+	Key      *hlsKey
+	Sequence int64
+}
+
+// hlsDownload 一次 HLS 下载任务：解析后的分片列表及密钥缓存
+type hlsDownload struct {
+	policy      *ProxyPolicy
+	PlaylistURL string
+	Init        *downloadSegment // #EXT-X-MAP 初始化分片（fMP4）
+	Segments    []downloadSegment
+	concurrency int
+
+	keyMu sync.Mutex
+	keys  map[string][]byte
+}
+
+// resolveHLSDownload 获取播放列表（主播放列表时选择码率最高的子播放列表），解析出全部分片后过滤广告；
+// 请求遵循代理访问策略，分片和密钥的大小受 max_response_size_mb 限制
+func resolveHLSDownload(ctx context.Context, policy *ProxyPolicy, target string, adFilter *HLSAdFilter) (*hlsDownload, error) {
+	current := target
+	for depth := 0; depth <= maxPlaylistDepth; depth++ {
+		data, base, err := fetchPlaylist(ctx, policy.Client(), current)
+		if err != nil {
+			return nil, err
+		}
+
+		if variant := bestVariant(data, base); variant != "" {
+			current = variant
+			continue
+		}
+
+		d, err := parseDownloadPlaylist(data, base, adFilter)
+		if err != nil {
+			return nil, err
+		}
+		d.policy = policy
+		d.PlaylistURL = base.String()
+		d.concurrency = defaultDownloadConcurrency
+		d.keys = make(map[string][]byte)
+		return d, nil
+	}
+	return nil, fmt.Errorf("播放列表嵌套层数过多")
+}
+
+// fetchPlaylist 获取播放列表内容，返回重定向后的地址作为相对地址的基准
+func fetchPlaylist(ctx context.Context, client *http.Client, target string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("获取播放列表失败: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := readHLSPlaylist(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), []byte("#EXTM3U")) {
+		return nil, nil, fmt.Errorf("不是有效的 m3u8 播放列表")
+	}
+	return data, resp.Request.URL, nil
+}
+
+// bestVariant 主播放列表中 BANDWIDTH 最高的子播放列表地址，媒体播放列表返回空
+func bestVariant(data []byte, base *url.URL) string {
+	best, bestBandwidth := "", int64(-1)
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			continue
+		}
+		bandwidth, _ := strconv.ParseInt(parseHLSAttributes(line)["BANDWIDTH"], 10, 64)
+		// 下一个非注释行为子播放列表地址
+		for i+1 < len(lines) {
+			i++
+			uri := strings.TrimSpace(lines[i])
+			if uri == "" || strings.HasPrefix(uri, "#") {
+				continue
+			}
+			if bandwidth > bestBandwidth {
+				best, bestBandwidth = resolveSegmentURL(base, uri), bandwidth
+			}
+			break
+		}
+	}
+	return best
+}
+
+// parseDownloadPlaylist 解析媒体播放列表中的分片、加密信息和初始化分片；
+// 分片序号和密钥按原播放列表确定后再移除广告分片，未声明 IV 的分片仍按原序号解密
+func parseDownloadPlaylist(data []byte, base *url.URL, adFilter *HLSAdFilter) (*hlsDownload, error) {
+	playlist := parseHLSMediaPlaylist(data)
+	if playlist == nil {
+		return nil, fmt.Errorf("播放列表中没有分片")
+	}
+
+	d := &hlsDownload{}
+	var key *hlsKey
+	var sequence int64
+
+	applyTag := func(tag string) error {
+		switch {
+		case strings.HasPrefix(tag, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.ParseInt(strings.TrimPrefix(tag, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(tag, "#EXT-X-KEY:"):
+			attrs := parseHLSAttributes(tag)
+			switch attrs["METHOD"] {
+			case "NONE":
+				key = nil
+			case "AES-128":
+				key = &hlsKey{Method: "AES-128", URI: resolveSegmentURL(base, attrs["URI"])}
+				if iv := attrs["IV"]; iv != "" {
+					decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+					if err != nil || len(decoded) != aes.BlockSize {
+						return fmt.Errorf("无效的 IV: %s", iv)
+					}
+					key.IV = decoded
+				}
+			default:
+				return fmt.Errorf("不支持的加密方式: %s", attrs["METHOD"])
+			}
+		case strings.HasPrefix(tag, "#EXT-X-BYTERANGE"):
+			return errors.New("不支持使用 #EXT-X-BYTERANGE 的播放列表")
+		case strings.HasPrefix(tag, "#EXT-X-MAP:"):
+			if _, ok := parseHLSAttributes(tag)["BYTERANGE"]; ok {
+				return errors.New("不支持使用 #EXT-X-BYTERANGE 的播放列表")
+			}
+			if d.Init == nil {
+				d.Init = &downloadSegment{URL: resolveSegmentURL(base, parseHLSAttributes(tag)["URI"]), Key: key, Sequence: sequence}
+			}
+		}
+		return nil
+	}
+
+	for _, tag := range playlist.Header {
+		if err := applyTag(tag); err != nil {
+			return nil, err
+		}
+	}
+	for _, group := range playlist.Groups {
+		for _, segment := range group {
+			for _, tag := range segment.Tags {
+				if err := applyTag(tag); err != nil {
+					return nil, err
+				}
+			}
+			d.Segments = append(d.Segments, downloadSegment{
+				URL:      resolveSegmentURL(base, segment.URI),
+				Key:      key,
+				Sequence: sequence,
+			})
+			sequence++
+		}
+	}
+
+	if adFilter != nil && adFilter.filterPlaylist(playlist, base) > 0 {
+		kept := make(map[int64]bool)
+		for _, group := range playlist.Groups {
+			for _, segment := range group {
+				kept[segment.Sequence] = true
+			}
+		}
+		segments := d.Segments[:0]
+		for _, segment := range d.Segments {
+			if kept[segment.Sequence] {
+				segments = append(segments, segment)
+			}
+		}
+		d.Segments = segments
+	}
+	if len(d.Segments) == 0 {
+		return nil, fmt.Errorf("播放列表中没有分片")
+	}
+	return d, nil
+}
+
+// Ext 输出文件扩展名，fMP4 播放列表为 .mp4，其余为 .ts
+func (d *hlsDownload) Ext() string {
+	if d.Init != nil {
+		return ".mp4"
+	}
+	return ".ts"
+}
+
+// WriteTo 从第 start 个分片开始按顺序下载并写入 w，同时最多下载 concurrency 个分片；
+// 每写完一个分片调用 onSegment
+func (d *hlsDownload) WriteTo(ctx context.Context, w io.Writer, start int, onSegment func(index int, size int)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if d.Init != nil && start == 0 {
+		data, err := d.fetchSegment(ctx, *d.Init)
+		if err != nil {
+			return fmt.Errorf("下载初始化分片失败: %v", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	results := make([]chan result, len(d.Segments))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	slots := make(chan struct{}, d.concurrency)
+
+	// 按顺序占用槽位启动下载，写出后释放槽位，内存中最多缓存 concurrency 个分片
+	go func() {
+		for i := start; i < len(d.Segments); i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, ch chan result) {
+				data, err := d.fetchSegment(ctx, d.Segments[i])
+				ch <- result{data, err}
+			}(i, results[i])
+		}
+	}()
+
+	for i := start; i < len(d.Segments); i++ {
+		var res result
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-slots
+		if res.err != nil {
+			return fmt.Errorf("下载第 %d 个分片失败: %v", i+1, res.err)
+		}
+		if _, err := w.Write(res.data); err != nil {
+			return err
+		}
+		if onSegment != nil {
+			onSegment(i, len(res.data))
+		}
+	}
+	return nil
+}
+
+// fetchSegment 下载分片并在需要时解密，失败时重试
+func (d *hlsDownload) fetchSegment(ctx context.Context, segment downloadSegment) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt < segmentRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		data, err := d.fetch(ctx, segment.URL)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if segment.Key == nil {
+			return data, nil
+		}
+		return d.decrypt(ctx, segment, data)
+	}
+	return nil, lastErr
+}
+
+// decrypt 使用 AES-128-CBC 解密分片，IV 未声明时使用分片序号
+func (d *hlsDownload) decrypt(ctx context.Context, segment downloadSegment, data []byte) ([]byte, error) {
+	key, err := d.key(ctx, segment.Key.URI)
+	if err != nil {
+		return nil, err
+	}
+	iv := segment.Key.IV
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(segment.Sequence))
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("加密分片长度无效: %d", len(data))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// 去除 PKCS#7 填充
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(plain) {
+		return nil, errors.New("解密失败: 填充无效")
+	}
+	return plain[:len(plain)-padding], nil
+}
+
+// key 获取密钥，同一地址只请求一次
+func (d *hlsDownload) key(ctx context.Context, uri string) ([]byte, error) {
+	d.keyMu.Lock()
+	defer d.keyMu.Unlock()
+	if key, ok := d.keys[uri]; ok {
+		return key, nil
+	}
+	key, err := d.fetch(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("获取密钥失败: %v", err)
+	}
+	if len(key) != 16 {
+		return nil, fmt.Errorf("密钥长度无效: %d", len(key))
+	}
+	d.keys[uri] = key
+	return key, nil
+}
+
+// fetch 获取完整响应内容，大小受访问策略和单个分片上限限制
+func (d *hlsDownload) fetch(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := d.policy.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+	}
+	if d.policy.ExceedsLimit(resp.ContentLength) {
+		return nil, ErrResponseTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(d.policy.LimitBody(resp.Body), maxCacheEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCacheEntrySize {
+		return nil, ErrResponseTooLarge
+	}
+	return data, nil
+}
+
+// parseHLSAttributes 解析标签中的属性列表
+func parseHLSAttributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+	if idx := strings.Index(tag, ":"); idx >= 0 {
+		tag = tag[idx+1:]
+	}
+	for _, match := range hlsAttrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[match[1]] = strings.Trim(match[2], `"`)
+	}
+	return attrs
+}
+
+// downloadFilename 生成下载文件名：优先使用 name 参数，否则取播放列表地址中的文件名
+func downloadFilename(name, target, ext string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		if u, err := url.Parse(target); err == nil {
+			base := path.Base(u.Path)
+			name = strings.TrimSuffix(base, path.Ext(base))
+			// index.m3u8 之类的通用文件名改用上级目录名
+			if strings.EqualFold(name, "index") || strings.EqualFold(name, "playlist") {
+				name = path.Base(path.Dir(u.Path))
+			}
+		}
+	}
+	name = strings.TrimSpace(invalidFilenameChars.ReplaceAllString(name, "_"))
+	if name == "" || name == "." || name == "/" {
+		name = "video"
+	}
+	return name + ext
+}
+
+// contentDisposition 生成附件下载头，非 ASCII 文件名使用 RFC 5987 编码
+func contentDisposition(filename string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r > 0x7e || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, filename)
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, url.PathEscape(filename))
+}
+
+// DownloadHandler 处理 /api/download 请求：把 HLS 播放列表的全部分片按顺序拼接为单个文件返回
+func DownloadHandler(w http.ResponseWriter, r *http.Request, globalConfig interface{}) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target := strings.TrimSpace(r.URL.Query().Get("url"))
+	if target == "" {
+		writeDownloadError(w, http.StatusBadRequest, "缺少 url 参数")
+		return
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		writeDownloadError(w, http.StatusBadRequest, "无效的 url 参数")
+		return
+	}
+	if err := verifyRequestSignature(r, target, globalConfig); err != nil {
+		log.Printf("🚫 下载链接签名校验失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		writeDownloadError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	rt := currentProxyRuntime(globalConfig)
+	policy := rt.policy
+	if err := policy.CheckURL(targetURL); err != nil {
+		log.Printf("🚫 %v [IP:%s]", err, utils.GetRequestIP(r))
+		writeDownloadError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	concurrency := defaultDownloadConcurrency
+	if value := r.URL.Query().Get("concurrency"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			concurrency = n
+		}
+	}
+	if concurrency > maxDownloadConcurrency {
+		concurrency = maxDownloadConcurrency
+	}
+
+	log.Printf("📥 开始解析下载播放列表: %s [IP:%s]", target, utils.GetRequestIP(r))
+	d, err := resolveHLSDownload(r.Context(), policy, target, rt.adFilter)
+	if err != nil {
+		log.Printf("❌ 解析下载播放列表失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		writeDownloadError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	d.concurrency = concurrency
+	filename := downloadFilename(r.URL.Query().Get("name"), target, d.Ext())
+
+	if d.Ext() == ".mp4" {
+		w.Header().Set("Content-Type", "video/mp4")
+	} else {
+		w.Header().Set("Content-Type", "video/mp2t")
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(filename))
+	w.Header().Set("X-Segment-Count", strconv.Itoa(len(d.Segments)))
+	w.WriteHeader(http.StatusOK)
+
+	startTime := time.Now()
+	var written int64
+	err = d.WriteTo(r.Context(), w, 0, func(index int, size int) {
+		written += int64(size)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	})
+	if err != nil {
+		// 响应头已发送，只能中断传输
+		log.Printf("❌ 下载中断 %s: %v [IP:%s]", filename, err, utils.GetRequestIP(r))
+		return
+	}
+	log.Printf("✅ 下载完成 %s (%d 个分片, %d 字节, %.1fs) [IP:%s]", filename, len(d.Segments), written, time.Since(startTime).Seconds(), utils.GetRequestIP(r))
+}
+
+// writeDownloadError 返回JSON格式的错误信息
+func writeDownloadError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
@@ -0,0 +1,257 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+// encryptSegment 使用 AES-128-CBC 和 PKCS#7 填充加密分片
+func encryptSegment(t *testing.T, key, iv, plain []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+	return out
+}
+
+func sequenceIV(sequence uint64) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	return iv
+}
+
+// newTestDownloadPolicy 允许访问本机测试服务器的访问策略
+func newTestDownloadPolicy(maxResponseSizeMB int) *ProxyPolicy {
+	cfg := &utils.Config{}
+	cfg.Proxy.MaxResponseSizeMB = maxResponseSizeMB
+	return NewProxyPolicy(cfg)
+}
+
+func TestParseDownloadPlaylist(t *testing.T) {
+	base, _ := url.Parse("https://example.com/v/index.m3u8")
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-MEDIA-SEQUENCE:7\n" +
+		"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+		"#EXTINF:4,\n" +
+		"plain.m4s\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/k1\",IV=0x000102030405060708090a0b0c0d0e0f\n" +
+		"#EXTINF:4,\n" +
+		"enc-iv.m4s\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"k2\"\n" +
+		"#EXTINF:4,\n" +
+		"enc-seq.m4s\n" +
+		"#EXT-X-KEY:METHOD=NONE\n" +
+		"#EXTINF:4,\n" +
+		"https://cdn.example.com/last.m4s\n"
+
+	d, err := parseDownloadPlaylist([]byte(playlist), base, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Init == nil || d.Init.URL != "https://example.com/v/init.mp4" || d.Ext() != ".mp4" {
+		t.Errorf("init = %+v, ext = %s", d.Init, d.Ext())
+	}
+	if len(d.Segments) != 4 {
+		t.Fatalf("segments = %d, want 4", len(d.Segments))
+	}
+
+	want := []struct {
+		url      string
+		keyURI   string
+		sequence int64
+	}{
+		{"https://example.com/v/plain.m4s", "", 7},
+		{"https://example.com/v/enc-iv.m4s", "https://example.com/keys/k1", 8},
+		{"https://example.com/v/enc-seq.m4s", "https://example.com/v/k2", 9},
+		{"https://cdn.example.com/last.m4s", "", 10},
+	}
+	for i, w := range want {
+		s := d.Segments[i]
+		keyURI := ""
+		if s.Key != nil {
+			keyURI = s.Key.URI
+		}
+		if s.URL != w.url || keyURI != w.keyURI || s.Sequence != w.sequence {
+			t.Errorf("segment %d = %+v (key %q), want %+v", i, s, keyURI, w)
+		}
+	}
+	if iv := d.Segments[1].Key.IV; len(iv) != aes.BlockSize || iv[15] != 0x0f {
+		t.Errorf("explicit IV = %x", iv)
+	}
+	if d.Segments[2].Key.IV != nil {
+		t.Errorf("IV should default to the sequence number")
+	}
+
+	for _, bad := range []string{
+		"#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"k\"\n#EXTINF:4,\na.ts\n",
+		"#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\",IV=0x0102\n#EXTINF:4,\na.ts\n",
+		"#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nlow.m3u8\n",
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:1000@0\nall.ts\n",
+		"#EXTM3U\n#EXT-X-MAP:URI=\"all.mp4\",BYTERANGE=\"100@0\"\n#EXTINF:4,\nall.mp4\n",
+	} {
+		if _, err := parseDownloadPlaylist([]byte(bad), base, nil); err == nil {
+			t.Errorf("parseDownloadPlaylist(%q) should fail", bad)
+		}
+	}
+}
+
+func TestHLSDownloadDecrypt(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	explicitIV := []byte("fedcba9876543210")
+	segments := map[string][]byte{
+		"/seg0.ts": []byte("plain segment 0|"),
+		"/seg1.ts": encryptSegment(t, key, explicitIV, []byte("encrypted with explicit iv|")),
+		"/seg2.ts": encryptSegment(t, key, sequenceIV(12), []byte("encrypted with sequence iv, exactly 48 bytes!!|")),
+	}
+	keyRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.m3u8":
+			w.Write([]byte("#EXTM3U\n" +
+				"#EXT-X-MEDIA-SEQUENCE:10\n" +
+				"#EXTINF:4,\nseg0.ts\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\",IV=0x" + strings.ToUpper(hex.EncodeToString(explicitIV)) + "\n" +
+				"#EXTINF:4,\nseg1.ts\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n" +
+				"#EXTINF:4,\nseg2.ts\n"))
+		case "/key.bin":
+			keyRequests++
+			w.Write(key)
+		default:
+			data, ok := segments[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer srv.Close()
+
+	d, err := resolveHLSDownload(context.Background(), newTestDownloadPolicy(0), srv.URL+"/index.m3u8", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.concurrency = 1
+
+	var out bytes.Buffer
+	var written []int
+	if err := d.WriteTo(context.Background(), &out, 0, func(index, size int) { written = append(written, index) }); err != nil {
+		t.Fatal(err)
+	}
+	want := "plain segment 0|encrypted with explicit iv|encrypted with sequence iv, exactly 48 bytes!!|"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if len(written) != 3 || written[2] != 2 {
+		t.Errorf("onSegment indexes = %v", written)
+	}
+	if keyRequests != 1 {
+		t.Errorf("key fetched %d times, want 1", keyRequests)
+	}
+
+	// 从中间分片继续下载
+	out.Reset()
+	if err := d.WriteTo(context.Background(), &out, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "encrypted with sequence iv, exactly 48 bytes!!|" {
+		t.Errorf("resumed output = %q", out.String())
+	}
+}
+
+func TestHLSDownloadDecryptInvalid(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	d := &hlsDownload{keys: map[string][]byte{"k": key}}
+	segment := downloadSegment{Key: &hlsKey{Method: "AES-128", URI: "k"}}
+	if _, err := d.decrypt(context.Background(), segment, []byte("not a block")); err == nil {
+		t.Error("data that is not a multiple of the block size should fail")
+	}
+
+	// 明文最后一个字节为 0，不是有效的 PKCS#7 填充
+	block, _ := aes.NewCipher(key)
+	data := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, sequenceIV(0)).CryptBlocks(data, make([]byte, aes.BlockSize))
+	if _, err := d.decrypt(context.Background(), segment, data); err == nil {
+		t.Error("invalid padding should fail")
+	}
+}
+
+func TestParseDownloadPlaylistAdFilter(t *testing.T) {
+	base, _ := url.Parse("https://example.com/v/index.m3u8")
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n" +
+		"#EXTINF:10,\n0.ts\n" +
+		"#EXTINF:10,\n1.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXT-X-KEY:METHOD=NONE\n" +
+		"#EXTINF:3,\nhttps://ads.example.net/2.ts\n" +
+		"#EXTINF:3,\nhttps://ads.example.net/3.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n" +
+		"#EXTINF:10,\n4.ts\n" +
+		"#EXTINF:10,\n5.ts\n"
+	filter := newTestAdFilter(func(cfg *utils.Config) { cfg.AdFilter.HostMismatch = true })
+
+	d, err := parseDownloadPlaylist([]byte(playlist), base, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 广告之后的分片保留原序号，未声明 IV 时按原序号解密
+	want := []struct {
+		url      string
+		sequence int64
+	}{
+		{"https://example.com/v/0.ts", 0},
+		{"https://example.com/v/1.ts", 1},
+		{"https://example.com/v/4.ts", 4},
+		{"https://example.com/v/5.ts", 5},
+	}
+	if len(d.Segments) != len(want) {
+		t.Fatalf("segments = %+v", d.Segments)
+	}
+	for i, w := range want {
+		s := d.Segments[i]
+		if s.URL != w.url || s.Sequence != w.sequence || s.Key == nil || s.Key.IV != nil {
+			t.Errorf("segment %d = %+v, want %+v with sequence IV", i, s, w)
+		}
+	}
+}
+
+func TestHLSDownloadResponseLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.m3u8" {
+			w.Write([]byte("#EXTM3U\n#EXTINF:4,\nbig.ts\n"))
+			return
+		}
+		// 不声明长度，只能在读取时发现超限
+		w.(http.Flusher).Flush()
+		w.Write(make([]byte, 2<<20))
+	}))
+	defer srv.Close()
+
+	d, err := resolveHLSDownload(context.Background(), newTestDownloadPolicy(1), srv.URL+"/index.m3u8", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.fetch(context.Background(), d.Segments[0].URL); err != ErrResponseTooLarge {
+		t.Errorf("fetch() error = %v, want ErrResponseTooLarge", err)
+	}
+}
@@ -0,0 +1,709 @@
+package components
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// 下载任务状态
+const (
+	DownloadQueued    = "queued"
+	DownloadRunning   = "running"
+	DownloadPaused    = "paused"
+	DownloadCompleted = "completed"
+	DownloadFailed    = "failed"
+	DownloadCanceled  = "canceled" // 仅出现在进度事件中，取消的任务不再保留
+)
+
+// 下载管理参数
+const (
+	downloadJobsFile      = "jobs.json"
+	downloadPartsDir      = ".parts"
+	downloadSaveInterval  = 2 * time.Second  // 下载过程中保存任务进度的最小间隔
+	downloadEventKeepLive = 15 * time.Second // 进度事件流的心跳间隔
+)
+
+// DownloadJob 离线下载任务，对应某个视频源中视频的一集
+type DownloadJob struct {
+	ID          string `json:"id"`
+	Source      string `json:"source"`
+	VodID       string `json:"vod_id"`
+	Line        int    `json:"line"`    // 播放线路序号，从 0 开始
+	Episode     int    `json:"episode"` // 剧集序号，从 0 开始
+	Title       string `json:"title"`
+	EpisodeName string `json:"episode_name"`
+	URL         string `json:"url"`
+	Filename    string `json:"filename,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+
+	// TotalSegments 和 CompletedSegments 记录已按顺序写入的分片，Bytes 为对应的文件长度，
+	// 重启或恢复后从第 CompletedSegments 个分片继续下载
+	TotalSegments     int   `json:"total_segments"`
+	CompletedSegments int   `json:"completed_segments"`
+	Bytes             int64 `json:"bytes"`
+
+	CreatedAt  int64 `json:"created_at"`
+	UpdatedAt  int64 `json:"updated_at"`
+	FinishedAt int64 `json:"finished_at,omitempty"`
+}
+
+// DownloadManager 离线下载队列：按 max_jobs 限制同时下载的任务数，任务记录保存在 jobs.json，
+// 未完成的数据保存在 .parts 目录，完成后移动到下载目录
+type DownloadManager struct {
+	dir         string
+	maxJobs     int
+	concurrency int
+	config      func() *utils.Config // 当前生效的配置，访问策略和广告过滤随配置重载更新
+	sources     *SourcesConfig
+
+	mu          sync.Mutex
+	jobs        map[string]*DownloadJob
+	order       []string                      // 任务按创建顺序排列
+	running     map[string]context.CancelFunc // 正在下载的任务
+	stopping    map[string]string             // 正在停止的任务 → 停止后的状态（暂停或取消）
+	subscribers map[chan DownloadJob]struct{}
+	lastSave    time.Time
+}
+
+// NewDownloadManager 根据 [download] 配置创建下载管理器，加载之前的任务并继续未完成的下载；
+// 下载目录和并发数在创建时确定
+func NewDownloadManager(config func() *utils.Config, sources *SourcesConfig) (*DownloadManager, error) {
+	cfg := config()
+	m := &DownloadManager{
+		dir:         cfg.Download.Dir,
+		maxJobs:     cfg.Download.MaxJobs,
+		concurrency: cfg.Download.Concurrency,
+		config:      config,
+		sources:     sources,
+		jobs:        make(map[string]*DownloadJob),
+		running:     make(map[string]context.CancelFunc),
+		stopping:    make(map[string]string),
+		subscribers: make(map[chan DownloadJob]struct{}),
+	}
+	if m.dir == "" {
+		m.dir = "downloads"
+	}
+	if m.maxJobs <= 0 {
+		m.maxJobs = 2
+	}
+	if m.concurrency <= 0 {
+		m.concurrency = defaultDownloadConcurrency
+	}
+	if m.concurrency > maxDownloadConcurrency {
+		m.concurrency = maxDownloadConcurrency
+	}
+	if err := os.MkdirAll(filepath.Join(m.dir, downloadPartsDir), 0755); err != nil {
+		return nil, fmt.Errorf("创建下载目录失败: %v", err)
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.scheduleLocked()
+	m.mu.Unlock()
+	return m, nil
+}
+
+// load 读取 jobs.json，上次退出时正在下载的任务重新排队
+func (m *DownloadManager) load() error {
+	data, err := os.ReadFile(filepath.Join(m.dir, downloadJobsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取下载任务失败: %v", err)
+	}
+	var jobs []*DownloadJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("解析下载任务失败: %v", err)
+	}
+	for _, job := range jobs {
+		if job.Status == DownloadRunning {
+			job.Status = DownloadQueued
+		}
+		m.jobs[job.ID] = job
+		m.order = append(m.order, job.ID)
+	}
+	log.Printf("📥 已加载 %d 个下载任务", len(jobs))
+	return nil
+}
+
+// saveLocked 把全部任务写入 jobs.json，先写临时文件再重命名
+func (m *DownloadManager) saveLocked() {
+	jobs := make([]*DownloadJob, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id])
+	}
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		log.Printf("⚠️ 保存下载任务失败: %v", err)
+		return
+	}
+	path := filepath.Join(m.dir, downloadJobsFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		log.Printf("⚠️ 保存下载任务失败: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Printf("⚠️ 保存下载任务失败: %v", err)
+		return
+	}
+	m.lastSave = time.Now()
+}
+
+// List 按创建顺序返回全部任务
+func (m *DownloadManager) List() []DownloadJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]DownloadJob, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, *m.jobs[id])
+	}
+	return jobs
+}
+
+// Add 查询视频详情确定剧集的播放地址后加入下载队列，仅支持 m3u8 剧集
+func (m *DownloadManager) Add(ctx context.Context, sourceCode, vodID string, line, episode int) (DownloadJob, error) {
+	source := m.sources.GetSourceByCode(sourceCode)
+	if source == nil {
+		return DownloadJob{}, fmt.Errorf("视频源不存在: %s", sourceCode)
+	}
+	items, err := m.sources.detailSource(ctx, source, []string{vodID})
+	if err != nil {
+		return DownloadJob{}, fmt.Errorf("获取视频详情失败: %v", err)
+	}
+	if len(items) == 0 {
+		return DownloadJob{}, fmt.Errorf("视频不存在: %s", vodID)
+	}
+	item := items[0]
+	if line < 0 || line >= len(item.PlayLines) {
+		return DownloadJob{}, fmt.Errorf("播放线路不存在: %d", line)
+	}
+	episodes := item.PlayLines[line].Episodes
+	if episode < 0 || episode >= len(episodes) {
+		return DownloadJob{}, fmt.Errorf("剧集不存在: %d", episode)
+	}
+	if episodes[episode].Kind != EpisodeKindHLS {
+		return DownloadJob{}, errors.New("仅支持下载 m3u8 剧集")
+	}
+
+	now := time.Now().Unix()
+	job := &DownloadJob{
+		ID:          newDownloadID(),
+		Source:      sourceCode,
+		VodID:       vodID,
+		Line:        line,
+		Episode:     episode,
+		Title:       item.VodName,
+		EpisodeName: episodes[episode].Name,
+		URL:         episodes[episode].URL,
+		Status:      DownloadQueued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range m.order {
+		existing := m.jobs[id]
+		if existing.Source == job.Source && existing.VodID == job.VodID && existing.Line == job.Line && existing.Episode == job.Episode {
+			return DownloadJob{}, fmt.Errorf("任务已存在: %s", existing.ID)
+		}
+	}
+	m.jobs[job.ID] = job
+	m.order = append(m.order, job.ID)
+	m.publishLocked(*job)
+	m.scheduleLocked()
+	m.saveLocked()
+	return *m.jobs[job.ID], nil
+}
+
+// Pause 暂停任务，已下载的分片保留
+func (m *DownloadManager) Pause(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("任务不存在: %s", id)
+	}
+	switch job.Status {
+	case DownloadRunning:
+		// 下载协程退出后设置为暂停
+		m.stopping[id] = DownloadPaused
+		m.running[id]()
+	case DownloadQueued:
+		m.setStatusLocked(job, DownloadPaused, "")
+		m.saveLocked()
+	default:
+		return fmt.Errorf("任务状态为 %s，无法暂停", job.Status)
+	}
+	return nil
+}
+
+// Resume 继续暂停或失败的任务
+func (m *DownloadManager) Resume(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("任务不存在: %s", id)
+	}
+	if m.stopping[id] == DownloadPaused {
+		// 暂停尚未完成，下载协程退出后重新排队
+		m.stopping[id] = DownloadQueued
+		return nil
+	}
+	if job.Status != DownloadPaused && job.Status != DownloadFailed {
+		return fmt.Errorf("任务状态为 %s，无法继续", job.Status)
+	}
+	m.setStatusLocked(job, DownloadQueued, "")
+	m.scheduleLocked()
+	m.saveLocked()
+	return nil
+}
+
+// Cancel 取消任务并删除未完成的数据；deleteFile 为 true 时同时删除已完成的文件
+func (m *DownloadManager) Cancel(id string, deleteFile bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("任务不存在: %s", id)
+	}
+
+	delete(m.jobs, id)
+	for i, jobID := range m.order {
+		if jobID == id {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	if cancel, running := m.running[id]; running {
+		// 分片文件由下载协程退出后删除
+		m.stopping[id] = DownloadCanceled
+		cancel()
+	} else {
+		os.Remove(m.partPath(id))
+	}
+	if deleteFile && job.Status == DownloadCompleted && job.Filename != "" {
+		os.Remove(filepath.Join(m.dir, job.Filename))
+	}
+
+	canceled := *job
+	canceled.Status = DownloadCanceled
+	m.publishLocked(canceled)
+	m.scheduleLocked()
+	m.saveLocked()
+	return nil
+}
+
+// scheduleLocked 按创建顺序启动排队中的任务，直到达到 max_jobs
+func (m *DownloadManager) scheduleLocked() {
+	for _, id := range m.order {
+		if len(m.running) >= m.maxJobs {
+			return
+		}
+		job := m.jobs[id]
+		if job.Status != DownloadQueued {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.running[id] = cancel
+		m.setStatusLocked(job, DownloadRunning, "")
+		go m.run(ctx, id)
+	}
+}
+
+// run 执行下载并根据结果更新任务状态
+func (m *DownloadManager) run(ctx context.Context, id string) {
+	m.mu.Lock()
+	job := *m.jobs[id]
+	m.mu.Unlock()
+
+	log.Printf("📥 开始下载: %s %s", job.Title, job.EpisodeName)
+	ext, err := m.download(ctx, job)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running[id]()
+	delete(m.running, id)
+	next, stopped := m.stopping[id]
+	delete(m.stopping, id)
+
+	current, exists := m.jobs[id]
+	switch {
+	case !exists:
+		os.Remove(m.partPath(id))
+		log.Printf("🗑️ 下载已取消: %s %s", job.Title, job.EpisodeName)
+	case stopped:
+		m.setStatusLocked(current, next, "")
+		log.Printf("⏸️ 下载已暂停: %s %s (%d/%d)", job.Title, job.EpisodeName, current.CompletedSegments, current.TotalSegments)
+	case err != nil:
+		m.setStatusLocked(current, DownloadFailed, err.Error())
+		log.Printf("❌ 下载失败: %s %s: %v", job.Title, job.EpisodeName, err)
+	default:
+		// 与暂停、取消在同一把锁内移动文件，已移动的任务不会再被暂停后从头下载
+		if err := m.finishLocked(current, ext); err != nil {
+			m.setStatusLocked(current, DownloadFailed, err.Error())
+			log.Printf("❌ 下载失败: %s %s: %v", job.Title, job.EpisodeName, err)
+			break
+		}
+		current.FinishedAt = time.Now().Unix()
+		m.setStatusLocked(current, DownloadCompleted, "")
+		log.Printf("✅ 下载完成: %s (%d 字节)", current.Filename, current.Bytes)
+	}
+	m.scheduleLocked()
+	m.saveLocked()
+}
+
+// download 解析播放列表，从已完成的分片之后继续写入分片文件，返回输出文件扩展名；
+// 分片文件由 run 移动到下载目录
+func (m *DownloadManager) download(ctx context.Context, job DownloadJob) (string, error) {
+	target, err := url.Parse(job.URL)
+	if err != nil {
+		return "", fmt.Errorf("无效的播放地址: %v", err)
+	}
+	rt := currentProxyRuntime(m.config())
+	policy := rt.policy
+	if err := policy.CheckURL(target); err != nil {
+		return "", err
+	}
+	d, err := resolveHLSDownload(ctx, policy, job.URL, rt.adFilter)
+	if err != nil {
+		return "", err
+	}
+	d.concurrency = m.concurrency
+
+	part, err := os.OpenFile(m.partPath(job.ID), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer part.Close()
+
+	// 分片列表变化或数据文件不完整时从头下载
+	start, offset := job.CompletedSegments, job.Bytes
+	if info, err := part.Stat(); err != nil || job.TotalSegments != len(d.Segments) || info.Size() < offset {
+		start, offset = 0, 0
+	}
+	if err := part.Truncate(offset); err != nil {
+		return "", err
+	}
+	if _, err := part.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	m.update(job.ID, func(j *DownloadJob) {
+		j.TotalSegments = len(d.Segments)
+		j.CompletedSegments = start
+		j.Bytes = offset
+	}, true)
+
+	w := &countingWriter{w: part, n: offset}
+	err = d.WriteTo(ctx, w, start, func(index int, size int) {
+		m.update(job.ID, func(j *DownloadJob) {
+			j.CompletedSegments = index + 1
+			j.Bytes = w.n
+		}, false)
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := part.Close(); err != nil {
+		return "", err
+	}
+	return d.Ext(), nil
+}
+
+// finishLocked 将下载完成的分片文件移动到下载目录
+func (m *DownloadManager) finishLocked(job *DownloadJob, ext string) error {
+	name := job.Title
+	if job.EpisodeName != "" {
+		name += " " + job.EpisodeName
+	}
+	final := uniqueFilePath(m.dir, downloadFilename(name, job.URL, ext))
+	if err := os.Rename(m.partPath(job.ID), final); err != nil {
+		return fmt.Errorf("保存文件失败: %v", err)
+	}
+	job.Filename = filepath.Base(final)
+	return nil
+}
+
+// update 修改任务并推送进度，save 为 false 时按间隔保存
+func (m *DownloadManager) update(id string, fn func(job *DownloadJob), save bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+	fn(job)
+	job.UpdatedAt = time.Now().Unix()
+	m.publishLocked(*job)
+	if save || time.Since(m.lastSave) >= downloadSaveInterval {
+		m.saveLocked()
+	}
+}
+
+// setStatusLocked 设置任务状态并推送
+func (m *DownloadManager) setStatusLocked(job *DownloadJob, status, message string) {
+	job.Status = status
+	job.Error = message
+	job.UpdatedAt = time.Now().Unix()
+	m.publishLocked(*job)
+}
+
+// publishLocked 向进度事件订阅者推送任务，订阅者接收过慢时丢弃
+func (m *DownloadManager) publishLocked(job DownloadJob) {
+	for ch := range m.subscribers {
+		select {
+		case ch <- job:
+		default:
+		}
+	}
+}
+
+// subscribe 订阅任务进度
+func (m *DownloadManager) subscribe() chan DownloadJob {
+	ch := make(chan DownloadJob, 256)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+	return ch
+}
+
+// unsubscribe 取消订阅
+func (m *DownloadManager) unsubscribe(ch chan DownloadJob) {
+	m.mu.Lock()
+	delete(m.subscribers, ch)
+	m.mu.Unlock()
+}
+
+// partPath 任务未完成数据的保存路径
+func (m *DownloadManager) partPath(id string) string {
+	return filepath.Join(m.dir, downloadPartsDir, id+".part")
+}
+
+// HandleDownloadsAPI 处理 /api/downloads 接口：GET 返回任务列表，
+// POST 按 action 参数添加(add)、暂停(pause)、继续(resume)、取消(cancel)任务，DELETE 取消任务
+func (m *DownloadManager) HandleDownloadsAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	action := r.FormValue("action")
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "获取成功",
+			"data":    m.List(),
+		})
+		return
+	case "POST":
+		if action == "" {
+			action = "add"
+		}
+	case "DELETE":
+		action = "cancel"
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Method not allowed",
+		})
+		return
+	}
+
+	id := r.FormValue("id")
+	var data interface{}
+	var err error
+	switch action {
+	case "add":
+		line, _ := strconv.Atoi(r.FormValue("line"))
+		episode, _ := strconv.Atoi(r.FormValue("episode"))
+		vodID := r.FormValue("vod_id")
+		if r.FormValue("source") == "" || vodID == "" {
+			err = errors.New("缺少 source 或 vod_id 参数")
+			break
+		}
+		data, err = m.Add(r.Context(), r.FormValue("source"), vodID, line, episode)
+	case "pause":
+		err = m.Pause(id)
+	case "resume":
+		err = m.Resume(id)
+	case "cancel":
+		err = m.Cancel(id, r.FormValue("delete_file") == "true" || r.FormValue("delete_file") == "1")
+	default:
+		err = fmt.Errorf("不支持的操作: %s", action)
+	}
+
+	if err != nil {
+		log.Printf("❌ 下载任务操作失败 (%s): %v [IP:%s]", action, err, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "操作成功",
+		"data":    data,
+	})
+	log.Printf("✅ /api/downloads %s 请求 [IP:%s]", action, utils.GetRequestIP(r))
+}
+
+// HandleDownloadEventsAPI 处理 /api/downloads/events 接口：SSE 推送任务进度，
+// 连接后先推送 snapshot 事件（全部任务），之后每次任务变化推送 progress 事件
+func (m *DownloadManager) HandleDownloadEventsAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events := m.subscribe()
+	defer m.unsubscribe(events)
+
+	b, _ := json.Marshal(m.List())
+	fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", b)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(downloadEventKeepLive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case job := <-events:
+			b, _ := json.Marshal(job)
+			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", b)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// HandleDownloadFileAPI 处理 /api/downloads/file?id= 接口，返回已完成任务的文件，支持 Range
+func (m *DownloadManager) HandleDownloadFileAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Length, Content-Range, Accept-Ranges")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mu.Lock()
+	job, ok := m.jobs[r.URL.Query().Get("id")]
+	var filename string
+	if ok && job.Status == DownloadCompleted {
+		filename = job.Filename
+	}
+	m.mu.Unlock()
+	if filename == "" {
+		writeDownloadError(w, http.StatusNotFound, "文件不存在或下载未完成")
+		return
+	}
+
+	f, err := os.Open(filepath.Join(m.dir, filename))
+	if err != nil {
+		writeDownloadError(w, http.StatusNotFound, "文件不存在")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeDownloadError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if strings.HasSuffix(filename, ".mp4") {
+		w.Header().Set("Content-Type", "video/mp4")
+	} else {
+		w.Header().Set("Content-Type", "video/mp2t")
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(filename))
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+}
+
+// countingWriter 记录已写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// newDownloadID 生成随机任务ID
+func newDownloadID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// uniqueFilePath 目录中已有同名文件时在文件名后追加序号
+func uniqueFilePath(dir, filename string) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	path := filepath.Join(dir, filename)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
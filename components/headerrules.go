@@ -0,0 +1,87 @@
+package components
+
+import (
+	"net/http"
+	"strings"
+
+	"vastproxy-go/utils"
+)
+
+// headerRuleTransport 按目标主机应用 [headers.<名称>] 请求头规则，重定向后的每次请求都会重新匹配
+type headerRuleTransport struct {
+	base  http.RoundTripper
+	rules []utils.HeaderRule
+}
+
+// withHeaderRules 为 Transport 包装请求头规则，没有规则时原样返回
+func withHeaderRules(base http.RoundTripper, rules []utils.HeaderRule) http.RoundTripper {
+	if len(rules) == 0 {
+		return base
+	}
+	return &headerRuleTransport{base: base, rules: rules}
+}
+
+func (t *headerRuleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	var matched []utils.HeaderRule
+	for _, rule := range t.rules {
+		if matchHostPatterns(host, rule.Hosts) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTripper 不能修改调用方的请求，复制后再改写请求头
+	req = req.Clone(req.Context())
+	for _, rule := range matched {
+		applyHeaderRule(req.Header, rule)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// applyHeaderRule 依次移除、覆盖、追加请求头
+func applyHeaderRule(header http.Header, rule utils.HeaderRule) {
+	for _, name := range rule.Strip {
+		header.Del(name)
+	}
+	for name, value := range rule.Set {
+		header.Set(name, value)
+	}
+	for name, value := range rule.Add {
+		header.Add(name, value)
+	}
+}
+
+// matchHostPatterns 判断主机是否匹配任一规则：* 匹配全部，*.example.com 仅匹配子域名，
+// example.com 匹配该域名及其子域名
+func matchHostPatterns(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case host == pattern || strings.HasSuffix(host, "."+pattern):
+			return true
+		}
+	}
+	return false
+}
+
+// connectionHeaders 返回 Connection 头中声明的逐跳头部
+func connectionHeaders(header http.Header) map[string]bool {
+	names := make(map[string]bool)
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names[strings.ToLower(name)] = true
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,272 @@
+package components
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPlaylistSize 允许改写的播放列表最大字节数
+const maxPlaylistSize = 10 << 20
+
+// hlsURIAttrPattern 匹配标签中的 URI="..." 属性
+var hlsURIAttrPattern = regexp.MustCompile(`URI="([^"]*)"`)
+
+// isHLSPlaylist 根据 Content-Type、地址扩展名或内容开头判断响应是否为 m3u8 播放列表
+func isHLSPlaylist(resp *http.Response, head []byte) bool {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if strings.Contains(contentType, "mpegurl") {
+		return true
+	}
+
+	if resp.Request != nil && strings.EqualFold(path.Ext(resp.Request.URL.Path), ".m3u8") {
+		return true
+	}
+
+	// 部分源以 text/plain 或 octet-stream 返回播放列表，按内容识别
+	return bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))), []byte("#EXTM3U"))
+}
+
+// rewriteHLSPlaylist 将播放列表中的分片、密钥、初始化分片和子播放列表地址
+// 按 base 解析为绝对地址后交给 rewrite 改写
+func rewriteHLSPlaylist(body []byte, base *url.URL, rewrite func(string) string) []byte {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), maxPlaylistSize)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			out.WriteString(line)
+		case strings.HasPrefix(trimmed, "#"):
+			// #EXT-X-KEY、#EXT-X-MAP、#EXT-X-MEDIA、#EXT-X-I-FRAME-STREAM-INF 等标签中的 URI 属性
+			out.WriteString(hlsURIAttrPattern.ReplaceAllStringFunc(line, func(attr string) string {
+				uri := hlsURIAttrPattern.FindStringSubmatch(attr)[1]
+				return `URI="` + rewriteHLSURI(uri, base, rewrite) + `"`
+			}))
+		default:
+			// 分片或子播放列表地址
+			out.WriteString(rewriteHLSURI(trimmed, base, rewrite))
+		}
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes()
+}
+
+// rewriteHLSURI 解析相对地址，仅改写 http(s) 地址，其它协议（如 skd://、data:）保持原样
+func rewriteHLSURI(uri string, base *url.URL, rewrite func(string) string) string {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return uri
+	}
+	return rewrite(resolved.String())
+}
+
+// proxyURLFor 生成经由 /proxy 访问目标地址的链接，设置了签名器时生成签名链接
+func proxyURLFor(target string) string {
+	return signedURLFor(SignedPathProxy, target)
+}
+
+// downloadURLFor 生成经由 /api/download 下载目标播放列表的链接，设置了签名器时生成签名链接
+func downloadURLFor(target string) string {
+	return signedURLFor(SignedPathDownload, target)
+}
+
+// signedURLFor 生成接口链接，设置了签名器时附带签名
+func signedURLFor(endpoint, target string) string {
+	if signer := currentProxySigner(); signer != nil {
+		return signer.Sign(endpoint, target)
+	}
+	return endpoint + "?url=" + url.QueryEscape(target)
+}
+
+// hlsSegment 媒体播放列表中的一个分片
+type hlsSegment struct {
+	Tags     []string // 分片前的标签行（#EXTINF、#EXT-X-KEY 等）
+	URI      string
+	Duration float64
+	Sequence int64  // 原播放列表中的媒体序号
+	Key      string // 对该分片生效的 #EXT-X-KEY，未加密时为空
+	Map      string // 对该分片生效的 #EXT-X-MAP
+}
+
+// hlsMediaPlaylist 按 #EXT-X-DISCONTINUITY 分组的媒体播放列表
+type hlsMediaPlaylist struct {
+	Header []string       // 第一个分片之前的标签
+	Groups [][]hlsSegment // 以 DISCONTINUITY 分隔的分片组
+	Footer []string       // 最后一个分片之后的标签（#EXT-X-ENDLIST 等）
+}
+
+// parseHLSMediaPlaylist 解析媒体播放列表，主播放列表（不含 #EXTINF）返回 nil
+func parseHLSMediaPlaylist(body []byte) *hlsMediaPlaylist {
+	if !bytes.Contains(body, []byte("#EXTINF")) {
+		return nil
+	}
+
+	playlist := &hlsMediaPlaylist{Groups: [][]hlsSegment{{}}}
+	var pending []string
+	started := false
+	var sequence int64
+	var key, initMap string
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), maxPlaylistSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "#EXT-X-DISCONTINUITY":
+			if started {
+				playlist.Groups = append(playlist.Groups, []hlsSegment{})
+			}
+			started = true
+		case strings.HasPrefix(line, "#EXTINF"):
+			started = true
+			pending = append(pending, line)
+		case strings.HasPrefix(line, "#"):
+			switch {
+			case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+				sequence, _ = strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:")), 10, 64)
+			case strings.HasPrefix(line, "#EXT-X-KEY:"):
+				key = line
+				if parseHLSAttributes(line)["METHOD"] == "NONE" {
+					key = ""
+				}
+			case strings.HasPrefix(line, "#EXT-X-MAP:"):
+				initMap = line
+			}
+			if !started {
+				playlist.Header = append(playlist.Header, line)
+			} else {
+				pending = append(pending, line)
+			}
+		default:
+			started = true
+			segment := hlsSegment{Tags: pending, URI: line, Sequence: sequence, Key: key, Map: initMap}
+			sequence++
+			for _, tag := range pending {
+				if strings.HasPrefix(tag, "#EXTINF:") {
+					value := strings.TrimPrefix(tag, "#EXTINF:")
+					if idx := strings.Index(value, ","); idx >= 0 {
+						value = value[:idx]
+					}
+					segment.Duration, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+				}
+			}
+			last := len(playlist.Groups) - 1
+			playlist.Groups[last] = append(playlist.Groups[last], segment)
+			pending = nil
+		}
+	}
+	playlist.Footer = pending
+
+	// 去除空分组（如开头或连续的 DISCONTINUITY）
+	groups := playlist.Groups[:0]
+	for _, group := range playlist.Groups {
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	playlist.Groups = groups
+	return playlist
+}
+
+// Bytes 重新生成播放列表，分组之间插入 #EXT-X-DISCONTINUITY
+func (p *hlsMediaPlaylist) Bytes() []byte {
+	var out bytes.Buffer
+	for _, line := range p.Header {
+		out.WriteString(line + "\n")
+	}
+	for i, group := range p.Groups {
+		if i > 0 {
+			out.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		for _, segment := range group {
+			for _, tag := range segment.Tags {
+				out.WriteString(tag + "\n")
+			}
+			out.WriteString(segment.URI + "\n")
+		}
+	}
+	for _, line := range p.Footer {
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes()
+}
+
+// restoreSegmentState 移除部分分片后补写保留分片所需的标签：被移除分片上的 #EXT-X-KEY、#EXT-X-MAP
+// 重新写到之后的分片上；未声明 IV 的加密分片序号发生变化时，写入按原序号计算的 IV，
+// 否则播放器按新序号推导 IV 会导致解密出错
+func (p *hlsMediaPlaylist) restoreSegmentState() {
+	var sequence int64
+	var key, initMap string
+	for _, tag := range p.Header {
+		switch {
+		case strings.HasPrefix(tag, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(tag, "#EXT-X-MEDIA-SEQUENCE:")), 10, 64)
+		case strings.HasPrefix(tag, "#EXT-X-KEY:"):
+			key = tag
+			if parseHLSAttributes(tag)["METHOD"] == "NONE" {
+				key = ""
+			}
+		case strings.HasPrefix(tag, "#EXT-X-MAP:"):
+			initMap = tag
+		}
+	}
+
+	for _, group := range p.Groups {
+		for i := range group {
+			segment := &group[i]
+			tags := make([]string, 0, len(segment.Tags)+2)
+			if segment.Map != "" && segment.Map != initMap {
+				tags = append(tags, segment.Map)
+				initMap = segment.Map
+			}
+			wantKey := segment.Key
+			if segment.Sequence != sequence {
+				wantKey = keyWithSequenceIV(segment.Key, segment.Sequence)
+			}
+			if wantKey != key {
+				if wantKey == "" {
+					tags = append(tags, "#EXT-X-KEY:METHOD=NONE")
+				} else {
+					tags = append(tags, wantKey)
+				}
+				key = wantKey
+			}
+			for _, tag := range segment.Tags {
+				if !strings.HasPrefix(tag, "#EXT-X-KEY:") && !strings.HasPrefix(tag, "#EXT-X-MAP:") {
+					tags = append(tags, tag)
+				}
+			}
+			segment.Tags = tags
+			sequence++
+		}
+	}
+}
+
+// keyWithSequenceIV 为未声明 IV 的 #EXT-X-KEY 补上由媒体序号得到的 IV
+func keyWithSequenceIV(key string, sequence int64) string {
+	if key == "" {
+		return ""
+	}
+	if _, ok := parseHLSAttributes(key)["IV"]; ok {
+		return key
+	}
+	return fmt.Sprintf("%s,IV=0x%032X", key, sequence)
+}
@@ -0,0 +1,120 @@
+package components
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRewriteHLSPlaylist(t *testing.T) {
+	rewrite := func(target string) string { return "/proxy?url=" + url.QueryEscape(target) }
+	tests := []struct {
+		name string
+		base string
+		in   string
+		want string
+	}{
+		{
+			name: "media playlist",
+			base: "https://cdn.example.com/video/20240101/abc/index.m3u8?token=1",
+			in: "#EXTM3U\r\n" +
+				"#EXT-X-VERSION:3\r\n" +
+				"#EXT-X-TARGETDURATION:10\r\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"key.key\",IV=0x01\r\n" +
+				"#EXT-X-MAP:URI=\"/init.mp4\"\r\n" +
+				"#EXTINF:10.0,\r\n" +
+				"seg0.ts\r\n" +
+				"\r\n" +
+				"#EXTINF:10.0,\r\n" +
+				"  ../other/seg1.ts?x=1  \r\n" +
+				"#EXTINF:5.0,\r\n" +
+				"https://other.example.com/seg2.ts\r\n" +
+				"#EXT-X-ENDLIST\r\n",
+			want: "#EXTM3U\n" +
+				"#EXT-X-VERSION:3\n" +
+				"#EXT-X-TARGETDURATION:10\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"/proxy?url=https%3A%2F%2Fcdn.example.com%2Fvideo%2F20240101%2Fabc%2Fkey.key\",IV=0x01\n" +
+				"#EXT-X-MAP:URI=\"/proxy?url=https%3A%2F%2Fcdn.example.com%2Finit.mp4\"\n" +
+				"#EXTINF:10.0,\n" +
+				"/proxy?url=https%3A%2F%2Fcdn.example.com%2Fvideo%2F20240101%2Fabc%2Fseg0.ts\n" +
+				"\n" +
+				"#EXTINF:10.0,\n" +
+				"/proxy?url=https%3A%2F%2Fcdn.example.com%2Fvideo%2F20240101%2Fother%2Fseg1.ts%3Fx%3D1\n" +
+				"#EXTINF:5.0,\n" +
+				"/proxy?url=https%3A%2F%2Fother.example.com%2Fseg2.ts\n" +
+				"#EXT-X-ENDLIST\n",
+		},
+		{
+			name: "master playlist",
+			base: "http://example.com/hls/master.m3u8",
+			in: "#EXTM3U\n" +
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",NAME=\"en\",URI=\"audio/en.m3u8\"\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=1280000,RESOLUTION=1280x720\n" +
+				"720p/index.m3u8\n" +
+				"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,URI=\"720p/iframe.m3u8\"\n",
+			want: "#EXTM3U\n" +
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",NAME=\"en\",URI=\"/proxy?url=http%3A%2F%2Fexample.com%2Fhls%2Faudio%2Fen.m3u8\"\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=1280000,RESOLUTION=1280x720\n" +
+				"/proxy?url=http%3A%2F%2Fexample.com%2Fhls%2F720p%2Findex.m3u8\n" +
+				"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,URI=\"/proxy?url=http%3A%2F%2Fexample.com%2Fhls%2F720p%2Fiframe.m3u8\"\n",
+		},
+		{
+			name: "non-http uris are kept",
+			base: "https://example.com/a/index.m3u8",
+			in: "#EXTM3U\n" +
+				"#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://key-id\",KEYFORMAT=\"com.apple.streamingkeydelivery\"\n" +
+				"#EXT-X-SESSION-KEY:METHOD=AES-128,URI=\"data:text/plain;base64,AAAA\"\n" +
+				"#EXTINF:4,\n" +
+				"seg.ts\n",
+			want: "#EXTM3U\n" +
+				"#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://key-id\",KEYFORMAT=\"com.apple.streamingkeydelivery\"\n" +
+				"#EXT-X-SESSION-KEY:METHOD=AES-128,URI=\"data:text/plain;base64,AAAA\"\n" +
+				"#EXTINF:4,\n" +
+				"/proxy?url=https%3A%2F%2Fexample.com%2Fa%2Fseg.ts\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, _ := url.Parse(tt.base)
+			if got := string(rewriteHLSPlaylist([]byte(tt.in), base, rewrite)); got != tt.want {
+				t.Errorf("rewriteHLSPlaylist()\n got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHLSMediaPlaylist(t *testing.T) {
+	if parseHLSMediaPlaylist([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nlow.m3u8\n")) != nil {
+		t.Fatal("master playlist should not be parsed as media playlist")
+	}
+
+	in := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:10\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:10,\n" +
+		"a.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:3.5,title\n" +
+		"b.ts\n" +
+		"#EXT-X-ENDLIST\n"
+	playlist := parseHLSMediaPlaylist([]byte(in))
+	if len(playlist.Groups) != 2 || len(playlist.Groups[0]) != 1 || len(playlist.Groups[1]) != 1 {
+		t.Fatalf("groups = %+v", playlist.Groups)
+	}
+	if d := playlist.Groups[1][0].Duration; d != 3.5 {
+		t.Errorf("duration = %v, want 3.5", d)
+	}
+
+	want := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:10\n" +
+		"#EXTINF:10,\n" +
+		"a.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:3.5,title\n" +
+		"b.ts\n" +
+		"#EXT-X-ENDLIST\n"
+	if got := string(playlist.Bytes()); got != want {
+		t.Errorf("Bytes()\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
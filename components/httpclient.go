@@ -0,0 +1,180 @@
+package components
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// 出站请求默认设置，配置文件未填写时使用
+const (
+	defaultUserAgent    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36"
+	defaultMaxRedirects = 10
+)
+
+// UpstreamDirect 上游代理设置为 direct 时不经过任何代理（包括环境变量中的代理）
+const UpstreamDirect = "direct"
+
+// httpSettings 出站 HTTP 设置，来自 [proxy] 配置
+type httpSettings struct {
+	userAgent          string
+	maxRedirects       int
+	disableCompression bool
+	upstreamProxy      string
+	headerRules        []utils.HeaderRule
+}
+
+// httpLayer 全局共享的出站 HTTP 层，按上游代理地址区分连接池
+var httpLayer = struct {
+	sync.RWMutex
+	settings   httpSettings
+	transports map[string]*http.Transport
+}{
+	settings:   httpSettings{userAgent: defaultUserAgent, maxRedirects: defaultMaxRedirects},
+	transports: make(map[string]*http.Transport),
+}
+
+// ConfigureHTTP 应用 [proxy] 中的出站设置，设置变化时已有连接池会在空闲后关闭并按新设置重建
+func ConfigureHTTP(cfg *utils.Config) {
+	settings := httpSettings{userAgent: defaultUserAgent, maxRedirects: defaultMaxRedirects}
+	if cfg != nil {
+		if ua := strings.TrimSpace(cfg.Proxy.UserAgent); ua != "" {
+			settings.userAgent = ua
+		}
+		if cfg.Proxy.MaxRedirects > 0 {
+			settings.maxRedirects = cfg.Proxy.MaxRedirects
+		}
+		settings.disableCompression = cfg.Proxy.DisableCompression
+		settings.headerRules = cfg.HeaderRules
+		settings.upstreamProxy = strings.TrimSpace(cfg.Proxy.UpstreamProxy)
+		if settings.upstreamProxy != "" && settings.upstreamProxy != UpstreamDirect {
+			if _, err := parseUpstreamProxy(settings.upstreamProxy); err != nil {
+				log.Printf("⚠️ 全局上游代理配置无效，将直接连接: %v", err)
+				settings.upstreamProxy = ""
+			}
+		}
+	}
+
+	httpLayer.Lock()
+	// 出站设置未变化时保留连接池，避免每次重载都断开上游的长连接
+	if reflect.DeepEqual(httpLayer.settings, settings) {
+		httpLayer.Unlock()
+		return
+	}
+	for _, transport := range httpLayer.transports {
+		transport.CloseIdleConnections()
+	}
+	httpLayer.settings = settings
+	httpLayer.transports = make(map[string]*http.Transport)
+	httpLayer.Unlock()
+}
+
+// UserAgent 返回出站请求默认的 User-Agent
+func UserAgent() string {
+	httpLayer.RLock()
+	defer httpLayer.RUnlock()
+	return httpLayer.settings.userAgent
+}
+
+// NewHTTPClient 创建使用共享连接池的客户端；upstream 为空时使用全局上游代理，
+// timeout 为 0 时不限制整体耗时
+func NewHTTPClient(upstream string, timeout time.Duration) *http.Client {
+	httpLayer.Lock()
+	defer httpLayer.Unlock()
+
+	upstream = resolveUpstream(upstream, httpLayer.settings)
+	transport, ok := httpLayer.transports[upstream]
+	if !ok {
+		var err error
+		transport, err = newTransport(upstream, httpLayer.settings, nil)
+		if err != nil {
+			log.Printf("⚠️ 上游代理配置无效，将直接连接: %v", err)
+			transport, _ = newTransport(UpstreamDirect, httpLayer.settings, nil)
+		}
+		httpLayer.transports[upstream] = transport
+	}
+
+	return &http.Client{
+		Transport:     withHeaderRules(transport, httpLayer.settings.headerRules),
+		Timeout:       timeout,
+		CheckRedirect: redirectLimit(httpLayer.settings.maxRedirects),
+	}
+}
+
+// resolveUpstream 确定实际使用的上游代理，单独配置优先于全局配置
+func resolveUpstream(upstream string, settings httpSettings) string {
+	if upstream = strings.TrimSpace(upstream); upstream != "" {
+		return upstream
+	}
+	return settings.upstreamProxy
+}
+
+// newTransport 创建带连接池的 Transport，control 用于在连接建立前检查目标地址
+func newTransport(upstream string, settings httpSettings, control func(network, address string, c syscall.RawConn) error) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	switch upstream {
+	case "":
+	case UpstreamDirect:
+		proxy = nil
+	default:
+		proxyURL, err := parseUpstreamProxy(upstream)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		DisableCompression:    settings.disableCompression,
+	}, nil
+}
+
+// parseUpstreamProxy 解析上游代理地址，支持 http、https 和 socks5
+func parseUpstreamProxy(value string) (*url.URL, error) {
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("上游代理地址 %q 无效: %v", value, err)
+	}
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("上游代理 %q 协议不受支持，仅支持 http、https、socks5", value)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("上游代理地址 %q 缺少主机", value)
+	}
+	return proxyURL, nil
+}
+
+// redirectLimit 按 max_redirects 限制重定向次数
+func redirectLimit(maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("重定向次数超过 %d 次", maxRedirects)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,32 @@
+package components
+
+import (
+	"testing"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+func TestConfigureHTTPKeepsTransports(t *testing.T) {
+	cfg := &utils.Config{}
+	cfg.Proxy.UserAgent = "test-agent"
+	ConfigureHTTP(cfg)
+	defer ConfigureHTTP(nil)
+
+	before := NewHTTPClient("", time.Second).Transport
+
+	// 内容相同的新配置对象沿用连接池
+	same := &utils.Config{}
+	same.Proxy.UserAgent = "test-agent"
+	ConfigureHTTP(same)
+	if NewHTTPClient("", time.Second).Transport != before {
+		t.Fatalf("出站设置未变化时连接池被重建")
+	}
+
+	changed := &utils.Config{}
+	changed.Proxy.UserAgent = "other-agent"
+	ConfigureHTTP(changed)
+	if NewHTTPClient("", time.Second).Transport == before {
+		t.Fatalf("出站设置变化后连接池未重建")
+	}
+}
@@ -0,0 +1,254 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// macCMSJSONAdapter MacCMS provide/vod JSON 协议适配器
+type macCMSJSONAdapter struct{}
+
+// Search 按关键词搜索
+func (a *macCMSJSONAdapter) Search(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error) {
+	params := macCMSListParams(query)
+	params.Set("wd", query.Keyword)
+	return a.fetchList(ctx, source, params)
+}
+
+// Latest 获取最近更新列表
+func (a *macCMSJSONAdapter) Latest(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error) {
+	return a.fetchList(ctx, source, macCMSListParams(query))
+}
+
+// Detail 按视频ID获取完整信息
+func (a *macCMSJSONAdapter) Detail(ctx context.Context, source *VideoSource, ids []string) ([]VideoItem, error) {
+	params := url.Values{}
+	params.Set("ac", "detail")
+	params.Set("ids", strings.Join(ids, ","))
+
+	list, err := a.fetchList(ctx, source, params)
+	if err != nil {
+		return nil, err
+	}
+	return list.List, nil
+}
+
+// Categories 获取分类列表
+func (a *macCMSJSONAdapter) Categories(ctx context.Context, source *VideoSource) ([]Category, error) {
+	params := url.Values{}
+	params.Set("ac", "list")
+
+	payload, err := a.fetch(ctx, source, params)
+	if err != nil {
+		return nil, err
+	}
+	return payload.Categories, nil
+}
+
+// fetch 请求JSON接口并按内容解析；响应无法解析时回退到同一源的XML接口
+func (a *macCMSJSONAdapter) fetch(ctx context.Context, source *VideoSource, params url.Values) (*macCMSPayload, error) {
+	body, err := fetchSourceData(ctx, source, buildSourceURL(source.URL, params), "application/json, text/plain, */*")
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := decodeMacCMSPayload(body)
+	if err == nil {
+		return payload, nil
+	}
+
+	log.Printf("⚠️ 视频源 %s JSON接口解析失败，尝试XML接口: %v", source.Code, err)
+	xmlBody, xmlErr := fetchSourceData(ctx, source, buildSourceURL(macCMSXMLBaseURL(source.URL), params), "application/xml, text/xml, */*")
+	if xmlErr != nil {
+		return nil, err
+	}
+	xmlPayload, xmlErr := decodeMacCMSPayload(xmlBody)
+	if xmlErr != nil {
+		return nil, err
+	}
+	return xmlPayload, nil
+}
+
+// fetchList 请求接口并提取视频列表
+func (a *macCMSJSONAdapter) fetchList(ctx context.Context, source *VideoSource, params url.Values) (*VideoList, error) {
+	payload, err := a.fetch(ctx, source, params)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.VideoList, nil
+}
+
+// macCMSPayload 解析后的接口响应，JSON 与 XML 格式统一为此结构
+type macCMSPayload struct {
+	VideoList
+	Categories []Category
+}
+
+// decodeMacCMSPayload 根据响应内容判断格式（JSON 或 XML）并解析，同时解析播放线路
+func decodeMacCMSPayload(body []byte) (*macCMSPayload, error) {
+	payload, err := decodeMacCMSBody(body)
+	if err != nil {
+		return nil, err
+	}
+	attachPlayLines(payload.List)
+	return payload, nil
+}
+
+// decodeMacCMSBody 按 JSON 或 XML 格式解析响应
+func decodeMacCMSBody(body []byte) (*macCMSPayload, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("响应内容为空")
+	}
+
+	if trimmed[0] == '<' {
+		result, err := parseMacCMSXML(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return result.toPayload(), nil
+	}
+
+	// 使用 json.Number 保留 vod_id 等数字字段的原始精度
+	var result map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %v", err)
+	}
+	return parseMacCMSJSON(result), nil
+}
+
+// parseMacCMSJSON 从JSON响应中提取视频列表和分类
+func parseMacCMSJSON(result map[string]interface{}) *macCMSPayload {
+	payload := &macCMSPayload{Categories: []Category{}}
+	payload.Page = getInt(result, "page")
+	payload.PageCount = getInt(result, "pagecount")
+	payload.Limit = getInt(result, "limit")
+	payload.Total = getInt(result, "total")
+
+	if list, ok := result["list"].([]interface{}); ok {
+		for _, item := range list {
+			if videoMap, ok := item.(map[string]interface{}); ok {
+				video := VideoItem{
+					VodID:         getString(videoMap, "vod_id"),
+					TypeID:        getString(videoMap, "type_id"),
+					TypeName:      getString(videoMap, "type_name"),
+					VodName:       getString(videoMap, "vod_name"),
+					VodSub:        getString(videoMap, "vod_sub"),
+					VodEn:         getString(videoMap, "vod_en"),
+					VodPic:        getString(videoMap, "vod_pic"),
+					VodYear:       getString(videoMap, "vod_year"),
+					VodArea:       getString(videoMap, "vod_area"),
+					VodLang:       getString(videoMap, "vod_lang"),
+					VodClass:      getString(videoMap, "vod_class"),
+					VodTag:        getString(videoMap, "vod_tag"),
+					VodScore:      getString(videoMap, "vod_score"),
+					VodDoubanID:   getString(videoMap, "vod_douban_id"),
+					VodBlurb:      getString(videoMap, "vod_blurb"),
+					VodContent:    getString(videoMap, "vod_content"),
+					VodActor:      getString(videoMap, "vod_actor"),
+					VodDirector:   getString(videoMap, "vod_director"),
+					VodPubdate:    getString(videoMap, "vod_pubdate"),
+					VodTime:       getString(videoMap, "vod_time"),
+					VodRemarks:    getString(videoMap, "vod_remarks"),
+					VodSerial:     getString(videoMap, "vod_serial"),
+					VodTotal:      getString(videoMap, "vod_total"),
+					VodPlayFrom:   getString(videoMap, "vod_play_from"),
+					VodPlayServer: getString(videoMap, "vod_play_server"),
+					VodPlayNote:   getString(videoMap, "vod_play_note"),
+					VodPlayUrl:    getString(videoMap, "vod_play_url"),
+				}
+				payload.List = append(payload.List, video)
+			}
+		}
+	} else if _, hasClass := result["class"]; !hasClass {
+		log.Printf("❌ 未找到list字段或格式不正确，result keys: %v", getMapKeys(result))
+	}
+
+	if class, ok := result["class"].([]interface{}); ok {
+		for _, item := range class {
+			if classMap, ok := item.(map[string]interface{}); ok {
+				payload.Categories = append(payload.Categories, Category{
+					TypeID:   getString(classMap, "type_id"),
+					TypePID:  getString(classMap, "type_pid"),
+					TypeName: getString(classMap, "type_name"),
+				})
+			}
+		}
+	}
+
+	return payload
+}
+
+// macCMSListParams 构建 videolist 接口的通用参数
+func macCMSListParams(query SourceQuery) url.Values {
+	params := url.Values{}
+	// 统一使用 videolist 接口
+	params.Set("ac", "videolist")
+	// 默认第一页
+	params.Set("pg", "1")
+	if query.Page != "" {
+		params.Set("pg", query.Page)
+	}
+	if query.TypeID != "" {
+		params.Set("t", query.TypeID)
+	}
+	if query.Hours != "" {
+		params.Set("h", query.Hours)
+	}
+	return params
+}
+
+// buildSourceURL 拼接源地址和查询参数
+func buildSourceURL(baseURL string, params url.Values) string {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return baseURL + "?" + params.Encode()
+}
+
+// fetchSourceData 请求源接口并返回响应内容，使用该源配置的上游代理
+func fetchSourceData(ctx context.Context, source *VideoSource, requestURL, accept string) ([]byte, error) {
+	// 使用共享连接池的HTTP客户端
+	client := NewHTTPClient(source.Proxy, 30*time.Second)
+
+	// 创建请求
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("User-Agent", UserAgent())
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	// 发送请求
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+	}
+
+	// 读取响应内容
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,211 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// macCMSXMLAdapter MacCMS provide/vod/at/xml 协议适配器
+type macCMSXMLAdapter struct{}
+
+// macCMSXMLResponse XML 接口的 <rss> 根节点
+type macCMSXMLResponse struct {
+	XMLName xml.Name `xml:"rss"`
+	List    struct {
+		Page        string           `xml:"page,attr"`
+		PageCount   string           `xml:"pagecount,attr"`
+		PageSize    string           `xml:"pagesize,attr"`
+		RecordCount string           `xml:"recordcount,attr"`
+		Videos      []macCMSXMLVideo `xml:"video"`
+	} `xml:"list"`
+	Class struct {
+		Types []macCMSXMLType `xml:"ty"`
+	} `xml:"class"`
+}
+
+// macCMSXMLVideo XML 接口中的 <video> 节点
+type macCMSXMLVideo struct {
+	Last     string `xml:"last"`
+	ID       string `xml:"id"`
+	TID      string `xml:"tid"`
+	Name     string `xml:"name"`
+	Type     string `xml:"type"`
+	Pic      string `xml:"pic"`
+	Lang     string `xml:"lang"`
+	Area     string `xml:"area"`
+	Year     string `xml:"year"`
+	State    string `xml:"state"`
+	Note     string `xml:"note"`
+	Actor    string `xml:"actor"`
+	Director string `xml:"director"`
+	Des      string `xml:"des"`
+	DL       struct {
+		DD []macCMSXMLPlayGroup `xml:"dd"`
+	} `xml:"dl"`
+}
+
+// macCMSXMLPlayGroup <dl><dd flag="..."> 播放组
+type macCMSXMLPlayGroup struct {
+	Flag string `xml:"flag,attr"`
+	URLs string `xml:",chardata"`
+}
+
+// macCMSXMLType <class><ty id="..."> 分类节点
+type macCMSXMLType struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:",chardata"`
+}
+
+// Search 按关键词搜索
+func (a *macCMSXMLAdapter) Search(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error) {
+	params := macCMSListParams(query)
+	params.Set("wd", query.Keyword)
+	return a.fetchList(ctx, source, params)
+}
+
+// Latest 获取最近更新列表
+func (a *macCMSXMLAdapter) Latest(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error) {
+	return a.fetchList(ctx, source, macCMSListParams(query))
+}
+
+// Detail 按视频ID获取完整信息，XML 接口的 videolist 即包含播放地址
+func (a *macCMSXMLAdapter) Detail(ctx context.Context, source *VideoSource, ids []string) ([]VideoItem, error) {
+	params := url.Values{}
+	params.Set("ac", "videolist")
+	params.Set("ids", strings.Join(ids, ","))
+
+	list, err := a.fetchList(ctx, source, params)
+	if err != nil {
+		return nil, err
+	}
+	return list.List, nil
+}
+
+// Categories 获取分类列表
+func (a *macCMSXMLAdapter) Categories(ctx context.Context, source *VideoSource) ([]Category, error) {
+	params := url.Values{}
+	params.Set("ac", "list")
+
+	payload, err := a.fetch(ctx, source, params)
+	if err != nil {
+		return nil, err
+	}
+	return payload.Categories, nil
+}
+
+// fetch 请求XML接口并按内容解析（部分站点的XML地址实际返回JSON）
+func (a *macCMSXMLAdapter) fetch(ctx context.Context, source *VideoSource, params url.Values) (*macCMSPayload, error) {
+	body, err := fetchSourceData(ctx, source, buildSourceURL(macCMSXMLBaseURL(source.URL), params), "application/xml, text/xml, */*")
+	if err != nil {
+		return nil, err
+	}
+	return decodeMacCMSPayload(body)
+}
+
+// fetchList 请求XML接口并提取视频列表
+func (a *macCMSXMLAdapter) fetchList(ctx context.Context, source *VideoSource, params url.Values) (*VideoList, error) {
+	payload, err := a.fetch(ctx, source, params)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.VideoList, nil
+}
+
+// toPayload 转换为统一的响应结构
+func (r *macCMSXMLResponse) toPayload() *macCMSPayload {
+	payload := &macCMSPayload{Categories: []Category{}}
+	payload.Page, _ = strconv.Atoi(r.List.Page)
+	payload.PageCount, _ = strconv.Atoi(r.List.PageCount)
+	payload.Limit, _ = strconv.Atoi(r.List.PageSize)
+	payload.Total, _ = strconv.Atoi(r.List.RecordCount)
+	for _, v := range r.List.Videos {
+		payload.List = append(payload.List, v.toVideoItem())
+	}
+	for _, ty := range r.Class.Types {
+		payload.Categories = append(payload.Categories, Category{
+			TypeID:   ty.ID,
+			TypeName: strings.TrimSpace(ty.Name),
+		})
+	}
+	return payload
+}
+
+// toVideoItem 转换为统一的 VideoItem，多个播放组按 $$$ 拼接，播放组的 flag 作为 vod_play_from
+func (v macCMSXMLVideo) toVideoItem() VideoItem {
+	var playFrom, playURLs []string
+	for _, dd := range v.DL.DD {
+		playFrom = append(playFrom, strings.TrimSpace(dd.Flag))
+		playURLs = append(playURLs, strings.TrimSpace(dd.URLs))
+	}
+
+	return VideoItem{
+		VodID:       strings.TrimSpace(v.ID),
+		TypeID:      strings.TrimSpace(v.TID),
+		VodName:     strings.TrimSpace(v.Name),
+		VodPic:      strings.TrimSpace(v.Pic),
+		VodYear:     strings.TrimSpace(v.Year),
+		TypeName:    strings.TrimSpace(v.Type),
+		VodContent:  strings.TrimSpace(v.Des),
+		VodActor:    strings.TrimSpace(v.Actor),
+		VodDirector: strings.TrimSpace(v.Director),
+		VodArea:     strings.TrimSpace(v.Area),
+		VodLang:     strings.TrimSpace(v.Lang),
+		VodTime:     strings.TrimSpace(v.Last),
+		VodRemarks:  strings.TrimSpace(v.Note),
+		VodSerial:   strings.TrimSpace(v.State),
+		VodPlayFrom: strings.Join(playFrom, "$$$"),
+		VodPlayUrl:  strings.Join(playURLs, "$$$"),
+	}
+}
+
+// parseMacCMSXML 解析 <rss><list><video> 格式的响应
+func parseMacCMSXML(body []byte) (*macCMSXMLResponse, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// 按声明的编码转换为 UTF-8，GBK/GB2312 等中文编码的采集站较常见
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return nil, fmt.Errorf("不支持的字符编码: %s", charset)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	decoder.Strict = false
+
+	var result macCMSXMLResponse
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析XML失败: %v", err)
+	}
+	return &result, nil
+}
+
+// macCMSXMLBaseURL 将 provide/vod 地址转换为 provide/vod/at/xml 地址：
+// 已有 /at/<格式> 时替换为 /at/xml，有 /from/<播放器> 时 /at/xml 放在其前面
+func macCMSXMLBaseURL(baseURL string) string {
+	base, query := baseURL, ""
+	if idx := strings.Index(baseURL, "?"); idx >= 0 {
+		base, query = baseURL[:idx], baseURL[idx:]
+	}
+	segments := strings.Split(strings.TrimSuffix(base, "/"), "/")
+
+	for i := len(segments) - 2; i >= 0; i-- {
+		if segments[i] == "at" {
+			segments[i+1] = "xml"
+			return strings.Join(segments, "/") + query
+		}
+	}
+	for i := len(segments) - 2; i >= 0; i-- {
+		if segments[i] == "from" {
+			segments = append(segments[:i], append([]string{"at", "xml"}, segments[i:]...)...)
+			return strings.Join(segments, "/") + query
+		}
+	}
+	return strings.Join(segments, "/") + "/at/xml" + query
+}
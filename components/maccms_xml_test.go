@@ -0,0 +1,123 @@
+package components
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestMacCMSXMLBaseURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com/api.php/provide/vod", "https://example.com/api.php/provide/vod/at/xml"},
+		{"https://example.com/api.php/provide/vod/", "https://example.com/api.php/provide/vod/at/xml"},
+		{"https://example.com/api.php/provide/vod/at/xml", "https://example.com/api.php/provide/vod/at/xml"},
+		{"https://example.com/api.php/provide/vod/at/xml/", "https://example.com/api.php/provide/vod/at/xml"},
+		{"https://example.com/api.php/provide/vod/at/json/", "https://example.com/api.php/provide/vod/at/xml"},
+		{"https://example.com/api.php/provide/vod/at/json", "https://example.com/api.php/provide/vod/at/xml"},
+		{"https://www.hongniuzy2.com/api.php/provide/vod/from/hnm3u8/", "https://www.hongniuzy2.com/api.php/provide/vod/at/xml/from/hnm3u8"},
+		{"https://example.com/api.php/provide/vod/at/json/from/lzm3u8", "https://example.com/api.php/provide/vod/at/xml/from/lzm3u8"},
+		{"https://example.com/api.php/provide/vod/?ac=list", "https://example.com/api.php/provide/vod/at/xml?ac=list"},
+	}
+	for _, tt := range tests {
+		if got := macCMSXMLBaseURL(tt.in); got != tt.want {
+			t.Errorf("macCMSXMLBaseURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// macCMSXMLFixture provide/vod/at/xml 的 videolist 响应，含两个播放组和分类
+const macCMSXMLFixture = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="5.1">
+<list page="2" pagecount="9" pagesize="20" recordcount="170">
+<video>
+<last>2024-05-01 12:00:00</last>
+<id>123</id>
+<tid>13</tid>
+<name><![CDATA[测试剧集]]></name>
+<type>国产剧</type>
+<pic>https://img.example.com/123.jpg</pic>
+<lang>国语</lang>
+<area>大陆</area>
+<year>2024</year>
+<state>0</state>
+<note><![CDATA[更新至2集]]></note>
+<actor><![CDATA[张三,李四]]></actor>
+<director><![CDATA[王五]]></director>
+<dl>
+<dd flag="lzm3u8"><![CDATA[第1集$https://v.example.com/1/index.m3u8#第2集$https://v.example.com/2/index.m3u8]]></dd>
+<dd flag="lzyun"><![CDATA[第1集$https://play.example.com/share/1#第2集$https://play.example.com/share/2]]></dd>
+</dl>
+<des><![CDATA[<p>剧情简介</p>]]></des>
+</video>
+</list>
+<class>
+<ty id="13">国产剧</ty>
+<ty id="14">香港剧</ty>
+</class>
+</rss>`
+
+func TestDecodeMacCMSXML(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(strings.Replace(macCMSXMLFixture, `encoding="utf-8"`, `encoding="GBK"`, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, body := range map[string]string{"utf-8": macCMSXMLFixture, "gbk": gbk} {
+		t.Run(name, func(t *testing.T) {
+			payload, err := decodeMacCMSPayload([]byte(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload.Page != 2 || payload.PageCount != 9 || payload.Limit != 20 || payload.Total != 170 {
+				t.Errorf("paging = %d/%d/%d/%d", payload.Page, payload.PageCount, payload.Limit, payload.Total)
+			}
+			if len(payload.Categories) != 2 || payload.Categories[1].TypeID != "14" || payload.Categories[1].TypeName != "香港剧" {
+				t.Errorf("categories = %+v", payload.Categories)
+			}
+			if len(payload.List) != 1 {
+				t.Fatalf("list = %+v", payload.List)
+			}
+
+			item := payload.List[0]
+			want := VideoItem{
+				VodID: "123", TypeID: "13", TypeName: "国产剧", VodName: "测试剧集",
+				VodPic: "https://img.example.com/123.jpg", VodYear: "2024", VodArea: "大陆", VodLang: "国语",
+				VodContent: "<p>剧情简介</p>", VodActor: "张三,李四", VodDirector: "王五",
+				VodTime: "2024-05-01 12:00:00", VodRemarks: "更新至2集", VodSerial: "0",
+				VodPlayFrom: "lzm3u8$$$lzyun",
+				VodPlayUrl: "第1集$https://v.example.com/1/index.m3u8#第2集$https://v.example.com/2/index.m3u8" +
+					"$$$第1集$https://play.example.com/share/1#第2集$https://play.example.com/share/2",
+			}
+			lines := item.PlayLines
+			item.PlayLines = nil
+			if !reflect.DeepEqual(item, want) {
+				t.Errorf("item = %+v\nwant %+v", item, want)
+			}
+
+			// 每个播放组一条线路，剧集按 # 拆分
+			if len(lines) != 2 || lines[0].From != "lzm3u8" || lines[1].From != "lzyun" {
+				t.Fatalf("play lines = %+v", lines)
+			}
+			for i, line := range lines {
+				if len(line.Episodes) != 2 || line.Episodes[0].Name != "第1集" || line.Episodes[1].Name != "第2集" {
+					t.Errorf("line %d episodes = %+v", i, line.Episodes)
+				}
+			}
+			if lines[0].Episodes[1].URL != "https://v.example.com/2/index.m3u8" || lines[0].Episodes[1].Kind != EpisodeKindHLS {
+				t.Errorf("hls episode = %+v", lines[0].Episodes[1])
+			}
+		})
+	}
+}
+
+func TestDecodeMacCMSXMLUnknownCharset(t *testing.T) {
+	body := strings.Replace(macCMSXMLFixture, `encoding="utf-8"`, `encoding="x-unknown"`, 1)
+	if _, err := decodeMacCMSPayload([]byte(body)); err == nil {
+		t.Error("unsupported charset should fail")
+	}
+}
@@ -0,0 +1,129 @@
+package components
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// 剧集播放类型
+const (
+	EpisodeKindHLS    = "hls"    // m3u8 流
+	EpisodeKindMP4    = "mp4"    // 可直接播放的视频文件
+	EpisodeKindIframe = "iframe" // 解析页或第三方播放页，需要嵌入播放
+)
+
+// 播放地址中的分隔符
+const (
+	playLineSeparator    = "$$$" // 线路分隔符
+	playEpisodeSeparator = "#"   // 剧集分隔符
+	playNameSeparator    = "$"   // 剧集名称与地址分隔符
+)
+
+// Episode 单集播放信息
+type Episode struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Kind     string `json:"kind"`
+	ProxyURL string `json:"proxy_url,omitempty"` // 经由 /proxy 播放的链接，仅 hls/mp4 类型提供
+	// DownloadURL 经由 /api/download 下载为单个文件的链接，仅 hls 类型提供
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// PlayLine 播放线路
+type PlayLine struct {
+	From     string    `json:"from"`
+	Note     string    `json:"note"`
+	Episodes []Episode `json:"episodes"`
+}
+
+// ParsePlayURL 解析 vod_play_url，线路按 $$$ 分隔，剧集按 # 分隔，名称与地址按 $ 分隔；
+// vod_play_from 和 vod_play_note 按 $$$ 与线路一一对应
+func ParsePlayURL(playURL, playFrom, playNote string) []PlayLine {
+	lines := []PlayLine{}
+	if strings.TrimSpace(playURL) == "" {
+		return lines
+	}
+
+	froms := strings.Split(playFrom, playLineSeparator)
+	notes := strings.Split(playNote, playLineSeparator)
+
+	for lineIdx, rawLine := range strings.Split(playURL, playLineSeparator) {
+		line := PlayLine{
+			From:     fmt.Sprintf("线路%d", lineIdx+1),
+			Episodes: []Episode{},
+		}
+		if lineIdx < len(froms) && strings.TrimSpace(froms[lineIdx]) != "" {
+			line.From = strings.TrimSpace(froms[lineIdx])
+		}
+		if lineIdx < len(notes) {
+			line.Note = strings.TrimSpace(notes[lineIdx])
+		}
+
+		for _, rawEpisode := range strings.Split(rawLine, playEpisodeSeparator) {
+			rawEpisode = strings.TrimSpace(rawEpisode)
+			if rawEpisode == "" {
+				continue
+			}
+
+			name, link := "", rawEpisode
+			if idx := strings.Index(rawEpisode, playNameSeparator); idx >= 0 {
+				name = strings.TrimSpace(rawEpisode[:idx])
+				link = strings.TrimSpace(rawEpisode[idx+len(playNameSeparator):])
+			}
+			if link == "" {
+				continue
+			}
+			if name == "" {
+				name = fmt.Sprintf("第%d集", len(line.Episodes)+1)
+			}
+
+			line.Episodes = append(line.Episodes, newEpisode(name, link))
+		}
+
+		// 只有分隔符或空地址的线路不返回
+		if len(line.Episodes) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// newEpisode 创建剧集，可直接播放的地址同时生成代理链接
+func newEpisode(name, link string) Episode {
+	episode := Episode{Name: name, URL: link, Kind: EpisodeKind(link)}
+	if episode.Kind != EpisodeKindIframe {
+		episode.ProxyURL = proxyURLFor(link)
+	}
+	if episode.Kind == EpisodeKindHLS {
+		episode.DownloadURL = downloadURLFor(link)
+	}
+	return episode
+}
+
+// EpisodeKind 根据地址扩展名判断播放类型
+func EpisodeKind(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return EpisodeKindIframe
+	}
+
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".m3u8":
+		return EpisodeKindHLS
+	case ".mp4", ".m4v", ".mov", ".webm", ".mkv", ".flv":
+		return EpisodeKindMP4
+	}
+	return EpisodeKindIframe
+}
+
+// attachPlayLines 为视频列表填充解析后的播放线路
+func attachPlayLines(items []VideoItem) {
+	for i := range items {
+		if items[i].VodPlayUrl != "" {
+			items[i].PlayLines = ParsePlayURL(items[i].VodPlayUrl, items[i].VodPlayFrom, items[i].VodPlayNote)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlayURL(t *testing.T) {
+	type episode struct{ name, url, kind string }
+	type line struct {
+		from, note string
+		episodes   []episode
+	}
+	tests := []struct {
+		name                string
+		playURL, from, note string
+		want                []line
+	}{
+		{
+			name:    "empty",
+			playURL: "  ",
+			want:    nil,
+		},
+		{
+			name:    "only separators",
+			playURL: "$$$",
+			want:    nil,
+		},
+		{
+			name:    "only episode separators and empty links",
+			playURL: "##第1集$#$$$#",
+			want:    nil,
+		},
+		{
+			name:    "multiple lines with from and note",
+			playURL: "第1集$https://a.com/1.m3u8#第2集$https://a.com/2.m3u8$$$第1集$https://b.com/1.mp4",
+			from:    "m3u8$$$mp4",
+			note:    "高清$$$备用",
+			want: []line{
+				{"m3u8", "高清", []episode{{"第1集", "https://a.com/1.m3u8", EpisodeKindHLS}, {"第2集", "https://a.com/2.m3u8", EpisodeKindHLS}}},
+				{"mp4", "备用", []episode{{"第1集", "https://b.com/1.mp4", EpisodeKindMP4}}},
+			},
+		},
+		{
+			name:    "episodes without name",
+			playURL: "https://a.com/1.m3u8#https://a.com/2.m3u8",
+			want: []line{
+				{"线路1", "", []episode{{"第1集", "https://a.com/1.m3u8", EpisodeKindHLS}, {"第2集", "https://a.com/2.m3u8", EpisodeKindHLS}}},
+			},
+		},
+		{
+			name:    "empty segments are skipped",
+			playURL: "#第1集$https://a.com/1.m3u8##正片$#$$$$$$第1集$https://b.com/1.mp4",
+			from:    "a$$$$$$c",
+			want: []line{
+				{"a", "", []episode{{"第1集", "https://a.com/1.m3u8", EpisodeKindHLS}}},
+				{"c", "", []episode{{"第1集", "https://b.com/1.mp4", EpisodeKindMP4}}},
+			},
+		},
+		{
+			name:    "missing from falls back to line number",
+			playURL: "正片$https://a.com/v.mp4$$$正片$https://c.com/play/1.html",
+			from:    "mp4",
+			want: []line{
+				{"mp4", "", []episode{{"正片", "https://a.com/v.mp4", EpisodeKindMP4}}},
+				{"线路2", "", []episode{{"正片", "https://c.com/play/1.html", EpisodeKindIframe}}},
+			},
+		},
+		{
+			name:    "mixed kinds",
+			playURL: "1$https://a.com/x/index.M3U8?t=1#2$https://a.com/2.webm#3$https://v.example.com/share/abc#4$ftp://a.com/4.mp4",
+			want: []line{
+				{"线路1", "", []episode{
+					{"1", "https://a.com/x/index.M3U8?t=1", EpisodeKindHLS},
+					{"2", "https://a.com/2.webm", EpisodeKindMP4},
+					{"3", "https://v.example.com/share/abc", EpisodeKindIframe},
+					{"4", "ftp://a.com/4.mp4", EpisodeKindIframe},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []line
+			for _, l := range ParsePlayURL(tt.playURL, tt.from, tt.note) {
+				gl := line{from: l.From, note: l.Note}
+				for _, e := range l.Episodes {
+					gl.episodes = append(gl.episodes, episode{e.Name, e.URL, e.Kind})
+					if (e.Kind == EpisodeKindIframe) != (e.ProxyURL == "") {
+						t.Errorf("episode %q kind %s has proxy_url %q", e.Name, e.Kind, e.ProxyURL)
+					}
+				}
+				got = append(got, gl)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePlayURL(%q)\n got  %+v\n want %+v", tt.playURL, got, tt.want)
+			}
+		})
+	}
+}
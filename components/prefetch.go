@@ -0,0 +1,326 @@
+package components
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// HLSPrefetcher 在播放器请求之前预取媒体播放列表中后续的分片，预取结果保存在有上限的内存缓冲中，
+// 启用磁盘缓存时同时写入缓存；播放会话空闲超时后停止预取
+type HLSPrefetcher struct {
+	depth       int
+	idleTimeout time.Duration
+	maxBuffer   int64
+	slots       chan struct{} // 限制同时进行的预取数量
+
+	mu       sync.Mutex
+	sessions map[string]*prefetchSession // 客户端IP + 播放列表 → 会话
+	segments map[string]*prefetchSession // 客户端IP + 分片 → 会话
+	items    map[string]*prefetchItem    // 分片 → 预取结果
+	order    *list.List                  // 已完成的预取结果，按完成顺序淘汰
+	size     int64
+	janitor  bool
+}
+
+// prefetchSession 一个客户端对一个媒体播放列表的播放会话
+type prefetchSession struct {
+	key      string
+	client   string
+	segments []*url.URL
+	keys     []string       // 与 segments 对应的缓存键，注册时计算一次
+	index    map[string]int // 分片缓存键 → 在 segments 中首次出现的位置
+	ctx      context.Context
+	cancel   context.CancelFunc
+	lastSeen time.Time
+}
+
+// prefetchItem 一个分片的预取结果，done 关闭后 data/err 可读
+type prefetchItem struct {
+	key         string
+	done        chan struct{}
+	data        []byte
+	contentType string
+	err         error
+}
+
+// NewHLSPrefetcher 根据 [prefetch] 配置创建预取器，未启用时返回 nil
+func NewHLSPrefetcher(cfg *utils.Config) *HLSPrefetcher {
+	if cfg == nil || !cfg.Prefetch.Enabled || cfg.Prefetch.Depth <= 0 {
+		return nil
+	}
+
+	concurrency := cfg.Prefetch.Concurrency
+	if concurrency <= 0 {
+		concurrency = 2
+	}
+	p := &HLSPrefetcher{
+		depth:       cfg.Prefetch.Depth,
+		idleTimeout: time.Duration(cfg.Prefetch.IdleTimeout) * time.Second,
+		maxBuffer:   int64(cfg.Prefetch.BufferMB) << 20,
+		slots:       make(chan struct{}, concurrency),
+		sessions:    make(map[string]*prefetchSession),
+		segments:    make(map[string]*prefetchSession),
+		items:       make(map[string]*prefetchItem),
+		order:       list.New(),
+	}
+	if p.idleTimeout <= 0 {
+		p.idleTimeout = 30 * time.Second
+	}
+	if p.maxBuffer <= 0 {
+		p.maxBuffer = 64 << 20
+	}
+	return p
+}
+
+// OnPlaylist 记录客户端获取的媒体播放列表（已过滤广告），并预取开头的分片
+func (p *HLSPrefetcher) OnPlaylist(client string, playlistURL, base *url.URL, data []byte, policy *ProxyPolicy, cache *ProxyCache) {
+	if p == nil {
+		return
+	}
+	playlist := parseHLSMediaPlaylist(data)
+	if playlist == nil {
+		return
+	}
+
+	var segments []*url.URL
+	for _, group := range playlist.Groups {
+		for _, segment := range group {
+			ref, err := url.Parse(segment.URI)
+			if err != nil {
+				continue
+			}
+			resolved := base.ResolveReference(ref)
+			if resolved.Scheme == "http" || resolved.Scheme == "https" {
+				segments = append(segments, resolved)
+			}
+		}
+	}
+	if len(segments) == 0 {
+		return
+	}
+	// 在加锁前计算缓存键，客户端请求分片时只需查表
+	keys := make([]string, len(segments))
+	index := make(map[string]int, len(segments))
+	for i, u := range segments {
+		keys[i] = cacheKey(u)
+		if _, exists := index[keys[i]]; !exists {
+			index[keys[i]] = i
+		}
+	}
+
+	key := client + "|" + cacheKey(playlistURL)
+	p.mu.Lock()
+	if old, ok := p.sessions[key]; ok {
+		// 直播播放列表刷新时替换分片列表，沿用会话
+		for _, segmentKey := range old.keys {
+			delete(p.segments, client+"|"+segmentKey)
+		}
+		old.segments, old.keys, old.index = segments, keys, index
+		old.lastSeen = time.Now()
+	} else {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.sessions[key] = &prefetchSession{
+			key:      key,
+			client:   client,
+			segments: segments,
+			keys:     keys,
+			index:    index,
+			ctx:      ctx,
+			cancel:   cancel,
+			lastSeen: time.Now(),
+		}
+	}
+	session := p.sessions[key]
+	for _, segmentKey := range keys {
+		p.segments[client+"|"+segmentKey] = session
+	}
+	p.startJanitorLocked()
+	p.mu.Unlock()
+
+	p.schedule(session, 0, policy, cache)
+}
+
+// OnSegment 客户端请求了某个分片，预取其后的分片
+func (p *HLSPrefetcher) OnSegment(client string, u *url.URL, policy *ProxyPolicy, cache *ProxyCache) {
+	if p == nil {
+		return
+	}
+	segmentKey := cacheKey(u)
+
+	p.mu.Lock()
+	session, ok := p.segments[client+"|"+segmentKey]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	session.lastSeen = time.Now()
+	index, found := session.index[segmentKey]
+	p.mu.Unlock()
+
+	if found {
+		p.schedule(session, index+1, policy, cache)
+	}
+}
+
+// Take 获取预取的分片，预取仍在进行时等待其完成；没有预取或预取失败时返回 false
+func (p *HLSPrefetcher) Take(ctx context.Context, u *url.URL) (*prefetchItem, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.mu.Lock()
+	item, ok := p.items[cacheKey(u)]
+	p.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	select {
+	case <-item.done:
+	case <-ctx.Done():
+		return nil, false
+	}
+	if item.err != nil {
+		return nil, false
+	}
+	return item, true
+}
+
+// Stop 停止所有会话的预取
+func (p *HLSPrefetcher) Stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, session := range p.sessions {
+		session.cancel()
+		delete(p.sessions, key)
+	}
+	p.segments = make(map[string]*prefetchSession)
+}
+
+// schedule 预取会话中从 start 开始的 depth 个分片，跳过已缓存或已在预取的分片
+func (p *HLSPrefetcher) schedule(session *prefetchSession, start int, policy *ProxyPolicy, cache *ProxyCache) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if session.ctx.Err() != nil {
+		return
+	}
+
+	for i := start; i < start+p.depth && i < len(session.segments); i++ {
+		u, key := session.segments[i], session.keys[i]
+		if _, exists := p.items[key]; exists || cache.Contains(u) {
+			continue
+		}
+		item := &prefetchItem{key: key, done: make(chan struct{})}
+		p.items[key] = item
+		go p.run(session.ctx, item, u, policy, cache)
+	}
+}
+
+// run 等待空闲的预取槽位后获取分片
+func (p *HLSPrefetcher) run(ctx context.Context, item *prefetchItem, u *url.URL, policy *ProxyPolicy, cache *ProxyCache) {
+	select {
+	case p.slots <- struct{}{}:
+		item.data, item.contentType, item.err = p.fetch(ctx, u, policy, cache)
+		<-p.slots
+	case <-ctx.Done():
+		item.err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	if item.err != nil {
+		// 失败的预取不保留，之后的请求直接访问源站
+		delete(p.items, item.key)
+		if !errors.Is(item.err, context.Canceled) {
+			log.Printf("⚠️ 预取分片失败: %s: %v", u.String(), item.err)
+		}
+	} else {
+		p.order.PushBack(item)
+		p.size += int64(len(item.data))
+		for p.size > p.maxBuffer && p.order.Len() > 0 {
+			oldest := p.order.Remove(p.order.Front()).(*prefetchItem)
+			delete(p.items, oldest.key)
+			p.size -= int64(len(oldest.data))
+		}
+	}
+	p.mu.Unlock()
+	close(item.done)
+}
+
+// fetch 获取分片内容，遵循代理的访问策略，启用缓存时同时写入缓存
+func (p *HLSPrefetcher) fetch(ctx context.Context, u *url.URL, policy *ProxyPolicy, cache *ProxyCache) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := policy.Client().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxCacheEntrySize || policy.ExceedsLimit(resp.ContentLength) {
+		return nil, "", ErrResponseTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(policy.LimitBody(resp.Body), maxCacheEntrySize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxCacheEntrySize {
+		return nil, "", ErrResponseTooLarge
+	}
+	cache.StoreSegment(u, resp, data)
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// startJanitorLocked 启动清理空闲会话的后台任务
+func (p *HLSPrefetcher) startJanitorLocked() {
+	if p.janitor {
+		return
+	}
+	p.janitor = true
+	go func() {
+		ticker := time.NewTicker(p.idleTimeout / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			p.mu.Lock()
+			for key, session := range p.sessions {
+				if time.Since(session.lastSeen) < p.idleTimeout {
+					continue
+				}
+				// 客户端已不再请求分片，取消进行中的预取
+				session.cancel()
+				delete(p.sessions, key)
+				for _, key := range session.keys {
+					segmentKey := session.client + "|" + key
+					if p.segments[segmentKey] == session {
+						delete(p.segments, segmentKey)
+					}
+				}
+			}
+			if len(p.sessions) == 0 {
+				p.janitor = false
+				p.mu.Unlock()
+				return
+			}
+			p.mu.Unlock()
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package components
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+func TestHLSPrefetcherOnSegment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	cfg := &utils.Config{}
+	cfg.Prefetch.Enabled = true
+	cfg.Prefetch.Depth = 1
+	p := NewHLSPrefetcher(cfg)
+	defer p.Stop()
+	policy := NewProxyPolicy(cfg)
+
+	base, _ := url.Parse(server.URL + "/video/index.m3u8")
+	playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n" +
+		"#EXTINF:4,\n0.ts\n#EXTINF:4,\n1.ts\n#EXTINF:4,\n2.ts\n#EXTINF:4,\n3.ts\n#EXT-X-ENDLIST\n"
+	p.OnPlaylist("client", base, base, []byte(playlist), policy, nil)
+
+	segment := func(name string) *url.URL {
+		u, _ := url.Parse(server.URL + "/video/" + name)
+		return u
+	}
+	session := p.segments["client|"+cacheKey(segment("2.ts"))]
+	if session == nil || session.index[cacheKey(segment("2.ts"))] != 2 {
+		t.Fatalf("分片未按位置登记到会话")
+	}
+
+	// 请求第 3 个分片后预取第 4 个
+	p.OnSegment("client", segment("2.ts"), policy, nil)
+	item, ok := p.Take(context.Background(), segment("3.ts"))
+	if !ok || string(item.data) != "/video/3.ts" {
+		t.Fatalf("未预取下一个分片: %+v", item)
+	}
+	if _, ok := p.Take(context.Background(), segment("1.ts")); ok {
+		t.Errorf("不应预取已播放位置之前的分片")
+	}
+
+	// 不属于会话的分片不触发预取
+	p.OnSegment("other", segment("0.ts"), policy, nil)
+	if _, ok := p.Take(context.Background(), segment("1.ts")); ok {
+		t.Errorf("其他客户端的请求触发了预取")
+	}
+}
@@ -1,23 +1,53 @@
 package components
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"vastproxy-go/utils"
 )
 
+// hopByHopHeaders 逐跳头部，不在代理两端之间转发
+var hopByHopHeaders = map[string]bool{
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"proxy-connection":    true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+}
+
 // ProxyHandler 处理代理请求
 func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface{}) {
 	startTime := time.Now()
 	fullQuery := r.URL.RawQuery
 	log.Printf("🔍 完整查询字符串: %s [IP:%s]", fullQuery, utils.GetRequestIP(r))
 
+	// 添加CORS头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range, If-Range")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges, X-Cache")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	urlParam := r.URL.Query().Get("url")
 	if urlParam == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -25,19 +55,29 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 		return
 	}
 
-	decodedURL, err := url.QueryUnescape(urlParam)
-	if err != nil {
-		log.Printf("❌ URL解码失败: %v [IP:%s]", err, utils.GetRequestIP(r))
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid URL encoding"))
-		return
+	// Query().Get 已完成一次解码；只有仍处于编码状态（二次编码）的地址才再解码，
+	// 避免破坏目标地址中本身带有的 %XX 转义
+	decodedURL := urlParam
+	if !strings.Contains(urlParam, "://") {
+		unescaped, err := url.QueryUnescape(urlParam)
+		if err != nil {
+			log.Printf("❌ URL解码失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid URL encoding"))
+			return
+		}
+		decodedURL = unescaped
 	}
-	log.Printf("🔍 解码后的URL: %s [IP:%s]", decodedURL, utils.GetRequestIP(r))
-	log.Printf("📋 来源IP: %s [IP:%s]", r.RemoteAddr, utils.GetRequestIP(r))
-	log.Printf("🔗 最终请求URL: %s [IP:%s]", decodedURL, utils.GetRequestIP(r))
+	log.Printf("🔗 最终请求URL: %s %s [IP:%s]", r.Method, decodedURL, utils.GetRequestIP(r))
 
-	// 构建请求
-	req, err := http.NewRequest("GET", decodedURL, nil)
+	// HEAD 原样转发，其余方法按 GET 请求目标
+	method := http.MethodGet
+	if r.Method == http.MethodHead {
+		method = http.MethodHead
+	}
+
+	// 构建请求，客户端断开时取消上游请求
+	req, err := http.NewRequestWithContext(r.Context(), method, decodedURL, nil)
 	if err != nil {
 		log.Printf("❌ 构建请求失败: %v [IP:%s]", err, utils.GetRequestIP(r))
 		w.WriteHeader(http.StatusBadRequest)
@@ -45,10 +85,37 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 		return
 	}
 
-	// 复制前端请求头，排除Host、Content-Length、Content-Encoding
+	// 校验签名链接
+	if err := verifyRequestSignature(r, decodedURL, globalConfig); err != nil {
+		log.Printf("🚫 代理链接签名校验失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// 检查目标地址的协议和域名，IP 地址在连接及每次重定向时检查
+	rt := currentProxyRuntime(globalConfig)
+	policy := rt.policy
+	if err := policy.CheckURL(req.URL); err != nil {
+		log.Printf("🚫 %v [IP:%s]", err, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// 复制前端请求头（包括 Range、If-Range），排除Host、Content-Length、Content-Encoding和逐跳头部；
+	// 浏览器的 Cookie 默认不转发，目标站点所需的请求头由 [headers.<名称>] 规则设置
+	forwardCookies := false
+	if cfg, ok := globalConfig.(*utils.Config); ok {
+		forwardCookies = cfg.Proxy.ForwardCookies
+	}
+	connectionScoped := connectionHeaders(r.Header)
 	for k, v := range r.Header {
 		kLower := strings.ToLower(k)
-		if kLower == "host" || kLower == "content-length" || kLower == "content-encoding" {
+		if kLower == "host" || kLower == "content-length" || kLower == "content-encoding" || hopByHopHeaders[kLower] || connectionScoped[kLower] {
+			continue
+		}
+		if kLower == "cookie" && !forwardCookies {
 			continue
 		}
 		for _, vv := range v {
@@ -57,18 +124,46 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 	}
 	// 设置 User-Agent
 	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+		req.Header.Set("User-Agent", UserAgent())
 	}
-	// 强制禁用压缩
+	// 强制禁用压缩，保证 Content-Length 和 Content-Range 与实际字节一致
 	req.Header.Set("Accept-Encoding", "identity")
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	// 请求的是预取会话中的分片时，继续预取其后的分片
+	cache := rt.cache
+	prefetcher := rt.prefetcher
+	prefetcher.OnSegment(utils.GetRequestIP(r), req.URL, policy, cache)
+
+	// 命中缓存时直接返回
+	if meta, dataPath, ok := cache.Get(req.URL); ok {
+		serveCached(w, r, req.URL, meta, dataPath, globalConfig)
+		return
+	}
+	if cache != nil {
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	// 已预取（或正在预取）的分片，等待预取完成后直接返回
+	if method == http.MethodGet {
+		if item, ok := prefetcher.Take(r.Context(), req.URL); ok {
+			w.Header().Set("X-Cache", "PREFETCH")
+			if item.contentType != "" {
+				w.Header().Set("Content-Type", item.contentType)
+			}
+			log.Printf("⚡ 预取命中分片 %s (%d 字节) [IP:%s]", req.URL.String(), len(item.data), utils.GetRequestIP(r))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(item.data))
+			return
+		}
 	}
-	resp, err := client.Do(req)
+
+	resp, err := policy.Client().Do(req)
 	if err != nil {
 		log.Printf("❌ 代理请求失败: %v [IP:%s]", err, utils.GetRequestIP(r))
-		if os.IsTimeout(err) {
+		var destErr *DestinationError
+		if errors.As(err, &destErr) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(destErr.Error()))
+		} else if os.IsTimeout(err) {
 			w.WriteHeader(http.StatusGatewayTimeout)
 			w.Write([]byte("Request timeout"))
 		} else {
@@ -80,32 +175,69 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 	defer resp.Body.Close()
 
 	requestTime := time.Since(startTime).Seconds()
-	log.Printf("✅ 目标服务器响应: %d (%.2fs) [IP:%s]", resp.StatusCode, requestTime, utils.GetRequestIP(r))
-	log.Printf("📥 响应头: %+v [IP:%s]", resp.Header, utils.GetRequestIP(r))
-	log.Printf("🔗 最终URL: %s [IP:%s]", resp.Request.URL.String(), utils.GetRequestIP(r))
+	log.Printf("✅ 目标服务器响应: %d (%.2fs) %s [IP:%s]", resp.StatusCode, requestTime, resp.Request.URL.String(), utils.GetRequestIP(r))
+
+	// 响应大小限制：声明长度超限时直接拒绝，未声明长度时在传输中截断
+	if policy.ExceedsLimit(resp.ContentLength) {
+		log.Printf("🚫 响应过大 (%d 字节) [IP:%s]", resp.ContentLength, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(ErrResponseTooLarge.Error()))
+		return
+	}
+	upstreamBody := policy.LimitBody(resp.Body)
+
+	// m3u8 播放列表：改写其中的地址，使分片、密钥和子播放列表也经由代理访问
+	if method == http.MethodGet && resp.StatusCode == http.StatusOK {
+		body := bufio.NewReader(upstreamBody)
+		if isHLSPlaylist(resp, peekPlaylistHead(resp, body)) {
+			data, err := readHLSPlaylist(body)
+			if err != nil {
+				log.Printf("❌ 读取播放列表失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			// 缓存原始播放列表，过滤和签名改写在每次返回时进行
+			cache.StorePlaylist(req.URL, resp, data)
+			writeHLSPlaylist(w, r, data, req.URL, resp.Request.URL, globalConfig)
+			return
+		}
+
+		// 分片在转发的同时写入缓存
+		segmentBody, abort := cache.TeeSegment(req.URL, r, resp, body)
+		defer abort()
+		relayResponse(w, r, resp, segmentBody)
+		return
+	}
 
-	// 读取前1000字节用于日志
-	preview := make([]byte, 1000)
-	n, _ := resp.Body.Read(preview)
-	log.Printf("📄 响应内容预览: %s... [IP:%s]", string(preview[:n]), utils.GetRequestIP(r))
+	relayResponse(w, r, resp, upstreamBody)
+}
 
-	// 重新构造响应体（包含预览和剩余内容）
-	bodyReader := io.MultiReader(strings.NewReader(string(preview[:n])), resp.Body)
+// peekPlaylistHead 仅在 Content-Type 无法确定类型时读取响应开头用于识别播放列表，
+// 视频文件和分片不会被预读
+func peekPlaylistHead(resp *http.Response, body *bufio.Reader) []byte {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "video/") || strings.HasPrefix(contentType, "audio/") ||
+		strings.HasPrefix(contentType, "image/") || strings.Contains(contentType, "mp2t") {
+		return nil
+	}
+	head, _ := body.Peek(16)
+	return head
+}
 
-	// 设置响应头，移除Content-Encoding、Transfer-Encoding、Content-Length
+// relayResponse 原样转发状态码、长度和范围相关头部以及响应体
+func relayResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, body io.Reader) {
+	// 复制响应头，保留 Content-Length、Content-Range、Accept-Ranges，移除逐跳头部
+	connectionScoped := connectionHeaders(resp.Header)
 	for k, v := range resp.Header {
 		kLower := strings.ToLower(k)
-		if kLower == "transfer-encoding" || kLower == "content-encoding" || kLower == "content-length" {
+		if hopByHopHeaders[kLower] || connectionScoped[kLower] || strings.HasPrefix(kLower, "access-control-") {
 			continue
 		}
 		for _, vv := range v {
 			w.Header().Add(k, vv)
 		}
 	}
-	// 添加CORS头
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range")
 
 	// JSON响应类型修正
 	if strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
@@ -113,10 +245,103 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 	}
 
 	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+
 	// 流式写入响应体
-	_, err = io.Copy(w, bodyReader)
+	written, err := io.Copy(w, body)
 	if err != nil {
 		log.Printf("⚠️ 流式传输异常: %v [IP:%s]", err, utils.GetRequestIP(r))
+		return
+	}
+	log.Printf("✅ 完成流式返回内容 (%d 字节) [IP:%s]", written, utils.GetRequestIP(r))
+}
+
+// readHLSPlaylist 读取播放列表内容，超过大小限制时返回错误
+func readHLSPlaylist(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxPlaylistSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPlaylistSize {
+		return nil, errors.New("Playlist too large")
+	}
+	return data, nil
+}
+
+// writeHLSPlaylist 过滤广告分片并改写地址后返回播放列表，相对地址以 base 为基准解析；
+// 启用预取时开始预取媒体播放列表开头的分片
+func writeHLSPlaylist(w http.ResponseWriter, r *http.Request, data []byte, playlistURL, base *url.URL, globalConfig interface{}) {
+	rt := currentProxyRuntime(globalConfig)
+	if rt.adFilter != nil {
+		var removed int
+		if data, removed = rt.adFilter.Filter(data, base); removed > 0 {
+			log.Printf("🧹 已过滤 %d 个广告分片 [IP:%s]", removed, utils.GetRequestIP(r))
+		}
+	}
+	rt.prefetcher.OnPlaylist(utils.GetRequestIP(r), playlistURL, base, data, rt.policy, rt.cache)
+
+	rewritten := rewriteHLSPlaylist(data, base, proxyURLFor)
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Length", strconv.Itoa(len(rewritten)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(rewritten)
+	}
+	log.Printf("✅ 完成播放列表改写返回 (%d 字节) [IP:%s]", len(rewritten), utils.GetRequestIP(r))
+}
+
+// serveCached 返回缓存中的播放列表或分片，分片支持 Range 请求
+func serveCached(w http.ResponseWriter, r *http.Request, target *url.URL, meta *cacheMeta, dataPath string, globalConfig interface{}) {
+	w.Header().Set("X-Cache", "HIT")
+	if meta.Playlist {
+		data, err := os.ReadFile(dataPath)
+		base, parseErr := url.Parse(meta.FinalURL)
+		if err != nil || parseErr != nil {
+			log.Printf("❌ 读取缓存播放列表失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Failed to read cache"))
+			return
+		}
+		log.Printf("💾 缓存命中播放列表 %s [IP:%s]", meta.URL, utils.GetRequestIP(r))
+		writeHLSPlaylist(w, r, data, target, base, globalConfig)
+		return
 	}
-	log.Printf("✅ 完成流式返回内容 [IP:%s]", utils.GetRequestIP(r))
+
+	file, err := os.Open(dataPath)
+	if err != nil {
+		log.Printf("❌ 读取缓存分片失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Failed to read cache"))
+		return
+	}
+	defer file.Close()
+	if meta.ContentType != "" {
+		w.Header().Set("Content-Type", meta.ContentType)
+	}
+	log.Printf("💾 缓存命中分片 %s (%d 字节) [IP:%s]", meta.URL, meta.Size, utils.GetRequestIP(r))
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
+
+// CacheStatsHandler 返回代理缓存的命中统计
+func CacheStatsHandler(w http.ResponseWriter, r *http.Request, globalConfig interface{}) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	stats := currentProxyRuntime(globalConfig).cache.Stats()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    stats,
+	})
+	log.Printf("✅ 返回代理缓存统计 (命中: %d, 未命中: %d) [IP:%s]", stats.Hits, stats.Misses, utils.GetRequestIP(r))
 }
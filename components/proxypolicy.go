@@ -0,0 +1,267 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// blockedPrefixes 禁止代理访问的内网、回环、链路本地等地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"), // 链路本地，含云厂商元数据地址
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// ErrResponseTooLarge 响应超过 max_response_size_mb 限制
+var ErrResponseTooLarge = errors.New("响应内容超过大小限制")
+
+// DestinationError 目标地址不符合代理访问策略
+type DestinationError struct {
+	Reason string
+}
+
+func (e *DestinationError) Error() string {
+	return "目标地址被拒绝: " + e.Reason
+}
+
+// ProxyPolicy /proxy 的目标访问策略
+type ProxyPolicy struct {
+	schemes         map[string]bool
+	blockPrivate    bool
+	allowDomains    []string
+	denyDomains     []string
+	maxRedirects    int
+	maxResponseSize int64
+	// viaUpstream 经由上游代理访问时无法检查实际连接的地址，改为请求前解析域名检查
+	viaUpstream bool
+	client      *http.Client
+}
+
+// NewProxyPolicy 根据 [proxy] 配置创建访问策略，配置为空时使用默认策略
+func NewProxyPolicy(cfg *utils.Config) *ProxyPolicy {
+	p := &ProxyPolicy{
+		schemes:      map[string]bool{"http": true, "https": true},
+		blockPrivate: true,
+		maxRedirects: 10,
+	}
+	if cfg != nil {
+		if schemes := splitList(cfg.Proxy.AllowedSchemes); len(schemes) > 0 {
+			p.schemes = make(map[string]bool)
+			for _, scheme := range schemes {
+				p.schemes[scheme] = true
+			}
+		}
+		p.blockPrivate = cfg.Proxy.BlockPrivate
+		p.allowDomains = splitList(cfg.Proxy.AllowDomains)
+		p.denyDomains = splitList(cfg.Proxy.DenyDomains)
+		if cfg.Proxy.MaxRedirects > 0 {
+			p.maxRedirects = cfg.Proxy.MaxRedirects
+		}
+		if cfg.Proxy.MaxResponseSizeMB > 0 {
+			p.maxResponseSize = int64(cfg.Proxy.MaxResponseSizeMB) << 20
+		}
+	}
+
+	// 使用出站 HTTP 层的设置；未配置上游代理时直接连接，不使用环境变量中的代理
+	httpLayer.RLock()
+	settings := httpLayer.settings
+	httpLayer.RUnlock()
+	upstream := settings.upstreamProxy
+	if upstream == "" {
+		upstream = UpstreamDirect
+	}
+	p.viaUpstream = upstream != UpstreamDirect
+
+	// 直接连接时在域名解析完成后检查实际连接的地址，防止通过解析到内网的域名绕过
+	var control func(network, address string, c syscall.RawConn) error
+	if !p.viaUpstream {
+		control = p.checkDialAddress
+	}
+	transport, err := newTransport(upstream, settings, control)
+	if err != nil {
+		log.Printf("⚠️ 代理服务上游代理配置无效，将直接连接: %v", err)
+		p.viaUpstream = false
+		transport, _ = newTransport(UpstreamDirect, settings, p.checkDialAddress)
+	}
+	p.client = &http.Client{
+		// 不设置整体超时，避免长视频在传输过程中被中断，
+		// 只限制等待响应头的时间，传输随客户端断开而取消
+		Transport: withHeaderRules(transport, settings.headerRules),
+		// 每次重定向都重新检查目标地址
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= p.maxRedirects {
+				return fmt.Errorf("重定向次数超过 %d 次", p.maxRedirects)
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+	return p
+}
+
+// Client 返回遵循该策略的 HTTP 客户端
+func (p *ProxyPolicy) Client() *http.Client {
+	return p.client
+}
+
+// CheckURL 检查目标地址的协议和域名；IP 地址在连接时检查
+func (p *ProxyPolicy) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemes[scheme] {
+		return &DestinationError{Reason: fmt.Sprintf("不允许的协议 %q", u.Scheme)}
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return &DestinationError{Reason: "缺少主机名"}
+	}
+	if matchDomain(host, p.denyDomains) {
+		return &DestinationError{Reason: fmt.Sprintf("域名 %s 在禁止列表中", host)}
+	}
+	if len(p.allowDomains) > 0 && !matchDomain(host, p.allowDomains) {
+		return &DestinationError{Reason: fmt.Sprintf("域名 %s 不在允许列表中", host)}
+	}
+	if p.blockPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return &DestinationError{Reason: "不允许访问本机地址"}
+	}
+	if p.blockPrivate && p.viaUpstream {
+		return p.checkResolvedHost(host)
+	}
+	return nil
+}
+
+// CheckDestination 检查目标地址的协议、域名，并在禁止内网地址时解析域名检查所有 IP 地址；
+// 用于保存前无法在连接时检查的地址，如管理接口提交的视频源地址
+func (p *ProxyPolicy) CheckDestination(u *url.URL) error {
+	if err := p.CheckURL(u); err != nil {
+		return err
+	}
+	if p.blockPrivate && !p.viaUpstream {
+		return p.checkResolvedHost(strings.ToLower(strings.TrimSuffix(u.Hostname(), ".")))
+	}
+	return nil
+}
+
+// checkResolvedHost 解析域名并检查所有地址，用于经由上游代理访问的情况
+func (p *ProxyPolicy) checkResolvedHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		// 本地无法解析的域名交由上游代理处理
+		return nil
+	}
+	for _, addr := range addrs {
+		if isBlockedIP(addr) {
+			return &DestinationError{Reason: fmt.Sprintf("不允许访问内网地址 %s", addr)}
+		}
+	}
+	return nil
+}
+
+// checkDialAddress 检查实际连接的 IP 地址
+func (p *ProxyPolicy) checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if !p.blockPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &DestinationError{Reason: fmt.Sprintf("无法识别的地址 %s", address)}
+	}
+	if isBlockedIP(addrPort.Addr()) {
+		return &DestinationError{Reason: fmt.Sprintf("不允许访问内网地址 %s", addrPort.Addr())}
+	}
+	return nil
+}
+
+// LimitBody 按 max_response_size_mb 限制响应体，超过时读取返回 ErrResponseTooLarge
+func (p *ProxyPolicy) LimitBody(body io.Reader) io.Reader {
+	if p.maxResponseSize <= 0 {
+		return body
+	}
+	return &limitedBody{r: body, remaining: p.maxResponseSize}
+}
+
+// ExceedsLimit 判断声明的响应长度是否超过限制
+func (p *ProxyPolicy) ExceedsLimit(contentLength int64) bool {
+	return p.maxResponseSize > 0 && contentLength > p.maxResponseSize
+}
+
+// limitedBody 超过限制时返回错误的 Reader，与 io.LimitReader 不同，截断不会被当作正常结束
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedBody) Read(b []byte) (int, error) {
+	if l.remaining <= 0 {
+		// 恰好读完限制长度时，确认上游是否还有剩余内容
+		var probe [1]byte
+		if n, err := l.r.Read(probe[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(b)) > l.remaining {
+		b = b[:l.remaining]
+	}
+	n, err := l.r.Read(b)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// isBlockedIP 判断地址是否属于禁止访问的地址段
+func isBlockedIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDomain 判断主机是否为列表中的域名或其子域名
+func matchDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList 拆分逗号分隔的配置项，统一为小写
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, strings.TrimPrefix(item, "."))
+		}
+	}
+	return items
+}
@@ -0,0 +1,236 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// 配置重载的触发方式
+const (
+	ReloadTriggerSignal = "SIGHUP"
+	ReloadTriggerFile   = "file"
+	ReloadTriggerAPI    = "api"
+	ReloadTriggerAdmin  = "admin" // 通过视频源管理接口修改
+)
+
+// ReloadResult 一次配置重载的结果
+type ReloadResult struct {
+	Time    int64  `json:"time"`
+	Trigger string `json:"trigger"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Sources int    `json:"sources"`
+	// RestartRequired 已修改但需要重启才能生效的配置项
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// ConfigReloader 持有当前生效的配置，收到 SIGHUP、配置文件变化或管理接口请求时重新加载。
+// 新配置解析成功后整体替换，进行中的请求继续使用开始时获取的配置
+type ConfigReloader struct {
+	path    string
+	sources *SourcesConfig
+	current atomic.Pointer[utils.Config]
+
+	mu      sync.Mutex // 保证同一时间只进行一次重载
+	modTime time.Time
+	size    int64
+	last    ReloadResult
+}
+
+// NewConfigReloader 创建配置重载器，path 为空表示使用内置配置，此时不支持重载
+func NewConfigReloader(path string, cfg *utils.Config, sources *SourcesConfig) *ConfigReloader {
+	c := &ConfigReloader{path: path, sources: sources}
+	c.current.Store(cfg)
+	if info, err := os.Stat(path); path != "" && err == nil {
+		c.modTime, c.size = info.ModTime(), info.Size()
+	}
+	return c
+}
+
+// Config 当前生效的配置
+func (c *ConfigReloader) Config() *utils.Config {
+	return c.current.Load()
+}
+
+// Path 配置文件路径，使用内置配置时为空
+func (c *ConfigReloader) Path() string {
+	return c.path
+}
+
+// LastResult 最近一次重载的结果，尚未重载时 Time 为 0
+func (c *ConfigReloader) LastResult() ReloadResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Reload 重新读取配置文件，配置和视频源都解析成功后才替换，失败时保持当前配置
+func (c *ConfigReloader) Reload(trigger string) ReloadResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := ReloadResult{Time: time.Now().Unix(), Trigger: trigger}
+	if err := c.reloadLocked(&result); err != nil {
+		result.Message = err.Error()
+		log.Printf("❌ 配置重载失败 (%s): %v", trigger, err)
+	} else {
+		result.Success = true
+		result.Message = "配置已重新加载"
+		log.Printf("🔄 配置已重新加载 (%s): %s，共 %d 个源", trigger, c.path, result.Sources)
+		for _, key := range result.RestartRequired {
+			log.Printf("⚠️ 配置项 %s 已修改，需要重启后生效", key)
+		}
+	}
+	c.last = result
+	return result
+}
+
+// reloadLocked 读取并应用配置文件
+func (c *ConfigReloader) reloadLocked(result *ReloadResult) error {
+	if c.path == "" {
+		return fmt.Errorf("当前使用内置配置，无法重新加载")
+	}
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	c.modTime, c.size = info.ModTime(), info.Size()
+
+	cfg, err := utils.LoadConfigFromData(data)
+	if err != nil {
+		return err
+	}
+	cfg.ResolvePaths(c.path)
+	issues := ValidateConfig(cfg, data)
+	if err := issues.Err(); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		log.Printf("⚠️ 配置警告 %s", issue)
+	}
+	sources := NewSourcesConfig()
+	if err := sources.LoadFromConfigFile(data); err != nil {
+		return fmt.Errorf("加载视频源配置失败: %v", err)
+	}
+
+	old := c.current.Load()
+	result.RestartRequired = restartRequiredChanges(old, cfg)
+	result.Sources = len(sources.GetSources())
+
+	// 强制校验签名时密钥不可用视为重载失败，保留当前配置，避免所有播放请求被拒绝
+	signer, err := newProxySignerFromConfig(cfg)
+	if err != nil {
+		if cfg.Proxy.RequireSignature {
+			return fmt.Errorf("加载代理签名密钥失败: %v", err)
+		}
+		log.Printf("⚠️ 加载代理签名密钥失败，播放地址将不签名: %v", err)
+	}
+
+	// 先更新依赖配置的全局状态，再替换配置；代理策略、缓存、预取器仅在对应分节变化时重建
+	ConfigureHTTP(cfg)
+	ConfigureProxyRuntime(cfg)
+	SetProxySigner(signer)
+	c.sources.mu.Lock()
+	c.sources.sources = sources.sources
+	c.sources.mu.Unlock()
+	c.current.Store(cfg)
+	return nil
+}
+
+// restartRequiredChanges 列出监听地址、日志等启动时应用的配置中发生变化的项
+func restartRequiredChanges(old, cfg *utils.Config) []string {
+	if old == nil {
+		return nil
+	}
+	var keys []string
+	if old.Server.Port != cfg.Server.Port {
+		keys = append(keys, "server.port")
+	}
+	if old.Server.Host != cfg.Server.Host {
+		keys = append(keys, "server.host")
+	}
+	if old.Logging != cfg.Logging {
+		keys = append(keys, "logging")
+	}
+	if old.Download.Dir != cfg.Download.Dir || old.Download.MaxJobs != cfg.Download.MaxJobs || old.Download.Concurrency != cfg.Download.Concurrency {
+		keys = append(keys, "download")
+	}
+	return keys
+}
+
+// Watch 按 interval 检查配置文件的修改时间和大小，发生变化时重新加载
+func (c *ConfigReloader) Watch(interval time.Duration) {
+	if c.path == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			info, err := os.Stat(c.path)
+			if err != nil {
+				// 编辑器保存时文件可能短暂不存在，下次再检查
+				continue
+			}
+			c.mu.Lock()
+			changed := !info.ModTime().Equal(c.modTime) || info.Size() != c.size
+			c.mu.Unlock()
+			if changed {
+				c.Reload(ReloadTriggerFile)
+			}
+		}
+	}()
+}
+
+// HandleReloadAPI 处理 /api/admin/reload 接口：GET 返回配置文件和最近一次重载结果，POST 立即重新加载；
+// 需要管理令牌
+func (c *ConfigReloader) HandleReloadAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+AdminTokenHeader)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		writeAdminError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !requireAdmin(w, r, c.Config()) {
+		return
+	}
+
+	var result ReloadResult
+	if r.Method == "POST" {
+		result = c.Reload(ReloadTriggerAPI)
+	} else {
+		result = c.LastResult()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Success && r.Method == "POST" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": r.Method == "GET" || result.Success,
+		"message": result.Message,
+		"data": map[string]interface{}{
+			"config_path": c.path,
+			"last_reload": result,
+		},
+	})
+	log.Printf("✅ /api/admin/reload %s 请求 [IP:%s]", r.Method, utils.GetRequestIP(r))
+}
@@ -0,0 +1,53 @@
+package components
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+func TestReloadKeepsRuntimeWhenSignerFails(t *testing.T) {
+	t.Setenv(utils.SourcesEnvVar, "")
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "signature.key")
+	if err := os.WriteFile(keyFile, []byte("invalid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.ini")
+	writeConfig := func(requireSignature string) {
+		data := "[server]\nport = 8228\n" +
+			"[features]\nproxy_service = true\n" +
+			"[proxy]\nrequire_signature = " + requireSignature + "\nsignature_key_file = " + keyFile + "\n" +
+			"[sources]\na.name = A\na.url = https://a.example.com/api.php/provide/vod\n"
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := &utils.Config{}
+	signer := NewProxyURLSigner(make([]byte, signingKeySize), time.Hour)
+	SetProxySigner(signer)
+	defer SetProxySigner(nil)
+	reloader := NewConfigReloader(path, old, NewSourcesConfig())
+
+	// 强制校验签名时密钥无效，重载失败并保留原配置与签名器
+	writeConfig("true")
+	if result := reloader.Reload("test"); result.Success {
+		t.Fatalf("密钥无效时重载应失败")
+	}
+	if reloader.Config() != old || currentProxySigner() != signer {
+		t.Fatalf("重载失败后配置或签名器被替换")
+	}
+
+	// 未强制校验时仅记录警告，播放地址不签名
+	writeConfig("false")
+	if result := reloader.Reload("test"); !result.Success {
+		t.Fatalf("未强制校验时重载应成功: %s", result.Message)
+	}
+	if reloader.Config() == old || currentProxySigner() != nil {
+		t.Fatalf("重载成功后应使用新配置且不签名")
+	}
+}
@@ -0,0 +1,96 @@
+package components
+
+import (
+	"log"
+	"reflect"
+	"sync"
+
+	"vastproxy-go/utils"
+)
+
+// proxyRuntime 代理服务的运行期组件：访问策略、广告过滤器、缓存与预取器
+type proxyRuntime struct {
+	cfg        *utils.Config
+	policy     *ProxyPolicy
+	adFilter   *HLSAdFilter
+	cache      *ProxyCache
+	prefetcher *HLSPrefetcher
+}
+
+// proxyRuntimeState 当前生效的运行期组件，由启动流程与配置重载器替换
+var proxyRuntimeState struct {
+	sync.Mutex
+	current *proxyRuntime
+}
+
+// ConfigureProxyRuntime 按配置构建代理服务的运行期组件，
+// 对应配置分节未变化的组件沿用旧实例，连接池、缓存索引与预取任务不受重载影响；
+// 需在 ConfigureHTTP 之后调用，访问策略依赖其出站设置
+func ConfigureProxyRuntime(cfg *utils.Config) {
+	proxyRuntimeState.Lock()
+	defer proxyRuntimeState.Unlock()
+	proxyRuntimeState.current = buildProxyRuntime(proxyRuntimeState.current, cfg)
+}
+
+// currentProxyRuntime 返回当前生效的运行期组件，尚未配置时按传入的配置构建
+func currentProxyRuntime(globalConfig interface{}) *proxyRuntime {
+	proxyRuntimeState.Lock()
+	defer proxyRuntimeState.Unlock()
+	if proxyRuntimeState.current == nil {
+		cfg, _ := globalConfig.(*utils.Config)
+		proxyRuntimeState.current = buildProxyRuntime(nil, cfg)
+	}
+	return proxyRuntimeState.current
+}
+
+// buildProxyRuntime 以旧组件为基础构建新组件，仅重建配置分节发生变化的部分
+func buildProxyRuntime(old *proxyRuntime, cfg *utils.Config) *proxyRuntime {
+	rt := &proxyRuntime{cfg: cfg}
+
+	if sectionUnchanged(old, cfg, func(c *utils.Config) interface{} { return []interface{}{c.Proxy, c.HeaderRules} }) {
+		rt.policy = old.policy
+	} else {
+		rt.policy = NewProxyPolicy(cfg)
+	}
+
+	if sectionUnchanged(old, cfg, func(c *utils.Config) interface{} { return c.AdFilter }) {
+		rt.adFilter = old.adFilter
+	} else {
+		rt.adFilter = NewHLSAdFilter(cfg)
+	}
+
+	if sectionUnchanged(old, cfg, func(c *utils.Config) interface{} { return c.Cache }) {
+		rt.cache = old.cache
+	} else {
+		cache, err := NewProxyCache(cfg)
+		if err != nil {
+			log.Printf("❌ 初始化代理缓存失败: %v", err)
+		} else if cache != nil {
+			stats := cache.Stats()
+			log.Printf("💾 代理缓存已启用: %s (%d 个对象, %d/%d 字节)", cache.dir, stats.Entries, stats.Size, stats.MaxSize)
+		}
+		rt.cache = cache
+	}
+
+	if sectionUnchanged(old, cfg, func(c *utils.Config) interface{} { return c.Prefetch }) {
+		rt.prefetcher = old.prefetcher
+	} else {
+		// 旧预取器的会话与缓冲随之释放
+		if old != nil {
+			old.prefetcher.Stop()
+		}
+		rt.prefetcher = NewHLSPrefetcher(cfg)
+	}
+	return rt
+}
+
+// sectionUnchanged 判断新旧配置中指定分节的内容是否一致
+func sectionUnchanged(old *proxyRuntime, cfg *utils.Config, section func(*utils.Config) interface{}) bool {
+	if old == nil {
+		return false
+	}
+	if old.cfg == nil || cfg == nil {
+		return old.cfg == cfg
+	}
+	return reflect.DeepEqual(section(old.cfg), section(cfg))
+}
@@ -0,0 +1,57 @@
+package components
+
+import (
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+func newTestRuntimeConfig() *utils.Config {
+	cfg := &utils.Config{}
+	cfg.AdFilter.Enabled = true
+	cfg.AdFilter.Rules = map[string]string{"example.com": `/ad/`}
+	cfg.Prefetch.Enabled = true
+	cfg.Prefetch.Depth = 2
+	return cfg
+}
+
+func TestBuildProxyRuntimeReusesUnchangedSections(t *testing.T) {
+	old := buildProxyRuntime(nil, newTestRuntimeConfig())
+	defer old.prefetcher.Stop()
+	if old.policy == nil || old.adFilter == nil || old.prefetcher == nil {
+		t.Fatalf("初始组件不完整: %+v", old)
+	}
+
+	// 内容相同的新配置对象沿用全部组件
+	same := buildProxyRuntime(old, newTestRuntimeConfig())
+	if same.policy != old.policy || same.adFilter != old.adFilter || same.prefetcher != old.prefetcher {
+		t.Fatalf("配置未变化时组件被重建")
+	}
+
+	// 只有变化的分节被重建
+	cfg := newTestRuntimeConfig()
+	cfg.Proxy.BlockPrivate = true
+	cfg.Prefetch.Depth = 3
+	changed := buildProxyRuntime(same, cfg)
+	defer changed.prefetcher.Stop()
+	if changed.policy == same.policy {
+		t.Errorf("[proxy] 变化后访问策略未重建")
+	}
+	if changed.prefetcher == same.prefetcher {
+		t.Errorf("[prefetch] 变化后预取器未重建")
+	}
+	if changed.adFilter != same.adFilter {
+		t.Errorf("[ad_filter] 未变化时过滤器被重建")
+	}
+}
+
+func TestBuildProxyRuntimeDisablesSection(t *testing.T) {
+	old := buildProxyRuntime(nil, newTestRuntimeConfig())
+	cfg := newTestRuntimeConfig()
+	cfg.AdFilter.Enabled = false
+	cfg.Prefetch.Enabled = false
+	rt := buildProxyRuntime(old, cfg)
+	if rt.adFilter != nil || rt.prefetcher != nil {
+		t.Fatalf("关闭的组件仍然存在: %+v", rt)
+	}
+}
@@ -0,0 +1,181 @@
+package components
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// 签名密钥长度（字节）
+const signingKeySize = 32
+
+// 默认签名有效期
+const defaultSignatureTTL = 6 * time.Hour
+
+var (
+	// ErrSignatureMissing 代理链接缺少签名
+	ErrSignatureMissing = errors.New("缺少签名参数")
+	// ErrSignatureExpired 代理链接已过期
+	ErrSignatureExpired = errors.New("链接已过期")
+	// ErrSignatureInvalid 签名校验失败
+	ErrSignatureInvalid = errors.New("签名无效")
+	// ErrSignerUnavailable 已启用签名校验但签名器未初始化
+	ErrSignerUnavailable = errors.New("签名校验不可用")
+)
+
+// 需要签名的接口，签名内容包含接口路径，一个接口的签名不能用于另一个接口
+const (
+	SignedPathProxy    = "/proxy"
+	SignedPathDownload = "/api/download"
+)
+
+// ProxyURLSigner 使用 HMAC-SHA256 为 /proxy、/api/download 链接签名
+type ProxyURLSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewProxyURLSigner 创建签名器，ttl 不大于 0 时使用默认有效期
+func NewProxyURLSigner(key []byte, ttl time.Duration) *ProxyURLSigner {
+	if ttl <= 0 {
+		ttl = defaultSignatureTTL
+	}
+	return &ProxyURLSigner{key: key, ttl: ttl}
+}
+
+// LoadOrCreateSigningKey 读取签名密钥文件，文件不存在时生成新密钥并保存
+func LoadOrCreateSigningKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, decodeErr := hex.DecodeString(strings.TrimSpace(string(data)))
+		if decodeErr != nil || len(key) < signingKeySize {
+			return nil, fmt.Errorf("签名密钥文件 %s 格式无效", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取签名密钥失败: %v", err)
+	}
+
+	key := make([]byte, signingKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成签名密钥失败: %v", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建密钥目录失败: %v", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("保存签名密钥失败: %v", err)
+	}
+	return key, nil
+}
+
+// Sign 生成带过期时间和签名的接口链接，endpoint 为 SignedPathProxy 或 SignedPathDownload
+func (s *ProxyURLSigner) Sign(endpoint, target string) string {
+	exp := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+	return endpoint + "?url=" + url.QueryEscape(target) + "&exp=" + exp + "&sig=" + s.signature(endpoint, target, exp)
+}
+
+// Verify 校验接口链接中目标地址的过期时间和签名
+func (s *ProxyURLSigner) Verify(endpoint, target, exp, sig string) error {
+	if exp == "" || sig == "" {
+		return ErrSignatureMissing
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.signature(endpoint, target, exp))) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expUnix {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// signature 计算接口路径、url 与过期时间的签名
+func (s *ProxyURLSigner) signature(endpoint, target, exp string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(endpoint))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(target))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyRequestSignature 启用 require_signature 时校验请求中的 exp、sig 参数，未启用时直接通过
+func verifyRequestSignature(r *http.Request, target string, globalConfig interface{}) error {
+	cfg, ok := globalConfig.(*utils.Config)
+	if !ok || !cfg.Proxy.RequireSignature {
+		return nil
+	}
+	signer := currentProxySigner()
+	if signer == nil {
+		return ErrSignerUnavailable
+	}
+	return signer.Verify(r.URL.Path, target, r.URL.Query().Get("exp"), r.URL.Query().Get("sig"))
+}
+
+// ConfigureProxySigner 按配置初始化全局签名器，首次启动时生成密钥；未启用代理服务时清除签名器。
+// 密钥加载失败且未开启 require_signature 时播放地址不签名，返回的错误仅需记录
+func ConfigureProxySigner(cfg *utils.Config) error {
+	signer, err := newProxySignerFromConfig(cfg)
+	SetProxySigner(signer)
+	return err
+}
+
+// newProxySignerFromConfig 按配置加载密钥并创建签名器，未启用代理服务时返回 nil
+func newProxySignerFromConfig(cfg *utils.Config) (*ProxyURLSigner, error) {
+	if cfg == nil || !cfg.Features.ProxyService {
+		return nil, nil
+	}
+
+	keyFile := cfg.Proxy.SignatureKeyFile
+	if keyFile == "" {
+		keyFile = utils.DefaultSignatureKeyFile
+	}
+	key, err := LoadOrCreateSigningKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🔑 代理链接签名已启用 (密钥: %s, 强制校验: %v)", keyFile, cfg.Proxy.RequireSignature)
+	return NewProxyURLSigner(key, time.Duration(cfg.Proxy.SignatureTTL)*time.Second), nil
+}
+
+var proxySigner struct {
+	sync.RWMutex
+	signer *ProxyURLSigner
+}
+
+// SetProxySigner 设置全局签名器，之后生成的播放地址和改写的播放列表都使用签名链接
+func SetProxySigner(signer *ProxyURLSigner) {
+	proxySigner.Lock()
+	defer proxySigner.Unlock()
+	proxySigner.signer = signer
+}
+
+// currentProxySigner 获取全局签名器，未设置时返回 nil
+func currentProxySigner() *ProxyURLSigner {
+	proxySigner.RLock()
+	defer proxySigner.RUnlock()
+	return proxySigner.signer
+}
@@ -0,0 +1,49 @@
+package components
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyURLSignerEndpointScope(t *testing.T) {
+	signer := NewProxyURLSigner([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	target := "https://example.com/video/index.m3u8"
+
+	for _, endpoint := range []string{SignedPathProxy, SignedPathDownload} {
+		link := signer.Sign(endpoint, target)
+		if !strings.HasPrefix(link, endpoint+"?") {
+			t.Fatalf("Sign(%s) = %s", endpoint, link)
+		}
+		u, _ := url.Parse(link)
+		q := u.Query()
+		if q.Get("url") != target {
+			t.Errorf("url = %q", q.Get("url"))
+		}
+		if err := signer.Verify(endpoint, target, q.Get("exp"), q.Get("sig")); err != nil {
+			t.Errorf("Verify(%s) = %v", endpoint, err)
+		}
+
+		other := SignedPathDownload
+		if endpoint == SignedPathDownload {
+			other = SignedPathProxy
+		}
+		if err := signer.Verify(other, target, q.Get("exp"), q.Get("sig")); err != ErrSignatureInvalid {
+			t.Errorf("signature for %s accepted by %s: %v", endpoint, other, err)
+		}
+		if err := signer.Verify(endpoint, target+"?x", q.Get("exp"), q.Get("sig")); err != ErrSignatureInvalid {
+			t.Errorf("signature accepted for another target: %v", err)
+		}
+	}
+
+	if err := signer.Verify(SignedPathProxy, target, "", ""); err != ErrSignatureMissing {
+		t.Errorf("missing signature = %v", err)
+	}
+	expired := NewProxyURLSigner(signer.key, -time.Hour)
+	expired.ttl = -time.Hour
+	u, _ := url.Parse(expired.Sign(SignedPathProxy, target))
+	if err := signer.Verify(SignedPathProxy, target, u.Query().Get("exp"), u.Query().Get("sig")); err != ErrSignatureExpired {
+		t.Errorf("expired signature = %v", err)
+	}
+}
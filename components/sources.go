@@ -1,15 +1,15 @@
 package components
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"vastproxy-go/utils"
 
@@ -22,35 +22,69 @@ type VideoSource struct {
 	Name      string `json:"name"`
 	URL       string `json:"url"`
 	IsDefault bool   `json:"is_default"`
+	Enabled   bool   `json:"enabled"`
+	Type      string `json:"type"`
+	Proxy     string `json:"-"` // 上游代理，为空时使用全局 upstream_proxy
 }
 
-// VideoItem 视频项目结构
+// VideoItem 视频项目结构，字段与 MacCMS 采集接口保持一致
 type VideoItem struct {
-	VodName     string `json:"vod_name"`
-	VodPic      string `json:"vod_pic"`
-	VodYear     string `json:"vod_year"`
-	TypeName    string `json:"type_name"`
-	VodScore    string `json:"vod_score"`
-	VodContent  string `json:"vod_content"`
-	VodActor    string `json:"vod_actor"`
-	VodDirector string `json:"vod_director"`
-	VodArea     string `json:"vod_area"`
-	VodLang     string `json:"vod_lang"`
-	VodTime     string `json:"vod_time"`
-	VodRemarks  string `json:"vod_remarks"`
-	VodPlayUrl  string `json:"vod_play_url"`
+	VodID         string `json:"vod_id"`
+	TypeID        string `json:"type_id"`
+	TypeName      string `json:"type_name"`
+	VodName       string `json:"vod_name"`
+	VodSub        string `json:"vod_sub"`
+	VodEn         string `json:"vod_en"`
+	VodPic        string `json:"vod_pic"`
+	VodYear       string `json:"vod_year"`
+	VodArea       string `json:"vod_area"`
+	VodLang       string `json:"vod_lang"`
+	VodClass      string `json:"vod_class"`
+	VodTag        string `json:"vod_tag"`
+	VodScore      string `json:"vod_score"`
+	VodDoubanID   string `json:"vod_douban_id"`
+	VodBlurb      string `json:"vod_blurb"`
+	VodContent    string `json:"vod_content"`
+	VodActor      string `json:"vod_actor"`
+	VodDirector   string `json:"vod_director"`
+	VodPubdate    string `json:"vod_pubdate"`
+	VodTime       string `json:"vod_time"`
+	VodRemarks    string `json:"vod_remarks"`
+	VodSerial     string `json:"vod_serial"`
+	VodTotal      string `json:"vod_total"`
+	VodPlayFrom   string `json:"vod_play_from"`
+	VodPlayServer string `json:"vod_play_server"`
+	VodPlayNote   string `json:"vod_play_note"`
+	VodPlayUrl    string `json:"vod_play_url"`
+
+	// PlayLines 由 vod_play_url 解析出的播放线路
+	PlayLines []PlayLine `json:"play_lines,omitempty"`
+}
+
+// VideoList 视频列表及分页信息
+type VideoList struct {
+	List      []VideoItem
+	Page      int
+	PageCount int
+	Limit     int
+	Total     int
 }
 
 // SearchResponse 搜索响应结构
 type SearchResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    []VideoItem `json:"data"`
-	Count   int         `json:"count"`
+	Success   bool        `json:"success"`
+	Message   string      `json:"message"`
+	Data      []VideoItem `json:"data"`
+	Count     int         `json:"count"`
+	Page      int         `json:"page"`
+	PageCount int         `json:"pagecount"`
+	Limit     int         `json:"limit"`
+	Total     int         `json:"total"`
 }
 
-// SourcesConfig 视频源配置管理器
+// SourcesConfig 视频源配置管理器，重新加载配置时整体替换视频源列表
 type SourcesConfig struct {
+	mu      sync.RWMutex
 	sources []VideoSource
 }
 
@@ -61,9 +95,9 @@ func NewSourcesConfig() *SourcesConfig {
 	}
 }
 
-// LoadFromConfigFile 从配置文件加载视频源
+// LoadFromConfigFile 从配置文件加载视频源，解析成功后替换当前的视频源列表
 func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
-	sc.sources = []VideoSource{}
+	sources := []VideoSource{}
 
 	// 解析INI配置文件
 	cfg, err := ini.Load(configData)
@@ -77,33 +111,21 @@ func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
 		return fmt.Errorf("配置文件中未找到 [sources] 部分")
 	}
 
-	// 用于临时存储源数据的map
+	// 用于临时存储源数据的map，order 记录源在配置文件中出现的顺序
 	sourceMap := make(map[string]map[string]string)
+	var order []string
+	collectSourceFields(sourcesSection, sourceMap, &order)
 
-	// 遍历所有配置项
-	for _, key := range sourcesSection.KeyStrings() {
-		value := sourcesSection.Key(key).String()
-
-		// 解析 key 格式: code.field
-		parts := strings.Split(key, ".")
-		if len(parts) != 2 {
-			continue // 跳过格式不正确的配置
+	// 环境变量中的视频源追加到配置文件之后，同名源的字段覆盖配置文件
+	if blob := strings.TrimSpace(os.Getenv(utils.SourcesEnvVar)); blob != "" {
+		if err := collectEnvSources(blob, sourceMap, &order); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %v", utils.SourcesEnvVar, err)
 		}
-
-		code := parts[0]
-		field := parts[1]
-
-		// 初始化源数据map
-		if sourceMap[code] == nil {
-			sourceMap[code] = make(map[string]string)
-		}
-
-		// 存储字段值
-		sourceMap[code][field] = strings.TrimSpace(value)
 	}
 
-	// 从map构建VideoSource对象
-	for code, fields := range sourceMap {
+	// 按配置顺序构建VideoSource对象
+	for _, code := range order {
+		fields := sourceMap[code]
 		// 检查必需字段
 		name, hasName := fields["name"]
 		url, hasURL := fields["url"]
@@ -117,26 +139,133 @@ func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
 			isDefault = isDefaultStr == "1" || strings.ToLower(isDefaultStr) == "true"
 		}
 
+		// 解析enabled字段，默认为true
+		enabled := true
+		if enabledStr, hasEnabled := fields["enabled"]; hasEnabled {
+			enabled = enabledStr == "1" || strings.ToLower(enabledStr) == "true"
+		}
+
+		// 解析type字段，决定使用的协议适配器，默认为maccms_json
+		sourceType := strings.ToLower(fields["type"])
+		if sourceType == "" {
+			sourceType = SourceTypeMacCMSJSON
+		}
+		if _, err := GetSourceAdapter(sourceType); err != nil {
+			log.Printf("⚠️ 视频源 %s: %v", code, err)
+		}
+		if proxy := fields["proxy"]; proxy != "" && proxy != UpstreamDirect {
+			if _, err := parseUpstreamProxy(proxy); err != nil {
+				log.Printf("⚠️ 视频源 %s: %v", code, err)
+			}
+		}
+
 		source := VideoSource{
 			Code:      code,
 			Name:      name,
 			URL:       url,
 			IsDefault: isDefault,
+			Enabled:   enabled,
+			Type:      sourceType,
+			Proxy:     fields["proxy"],
+		}
+
+		sources = append(sources, source)
+	}
+
+	sc.mu.Lock()
+	sc.sources = sources
+	sc.mu.Unlock()
+	return nil
+}
+
+// collectSourceFields 读取 code.field 格式的配置项，按源代码分组，新出现的源代码追加到 order
+func collectSourceFields(section *ini.Section, sourceMap map[string]map[string]string, order *[]string) {
+	for _, key := range section.KeyStrings() {
+		value := section.Key(key).String()
+
+		// 解析 key 格式: code.field
+		parts := strings.Split(key, ".")
+		if len(parts) != 2 || parts[1] == "" || !sourceCodePattern.MatchString(parts[0]) {
+			continue // 跳过格式不正确的配置
 		}
 
-		sc.sources = append(sc.sources, source)
+		code := parts[0]
+		field := parts[1]
+
+		// 初始化源数据map
+		if sourceMap[code] == nil {
+			sourceMap[code] = make(map[string]string)
+			*order = append(*order, code)
+		}
+
+		// 存储字段值
+		sourceMap[code][field] = strings.TrimSpace(value)
+	}
+}
+
+// collectEnvSources 解析 VASTVIDEO_SOURCES：JSON 数组（每项包含 code 及 name、url 等字段），
+// 或 code.field = 值 格式的 INI（可省略 [sources] 段名）
+func collectEnvSources(blob string, sourceMap map[string]map[string]string, order *[]string) error {
+	if strings.HasPrefix(blob, "[") && json.Valid([]byte(blob)) {
+		var entries []map[string]interface{}
+		if err := json.Unmarshal([]byte(blob), &entries); err != nil {
+			return fmt.Errorf("JSON 需要为对象数组: %v", err)
+		}
+		for i, entry := range entries {
+			code := getString(entry, "code")
+			if code == "" {
+				return fmt.Errorf("第 %d 个视频源缺少 code", i+1)
+			}
+			if sourceMap[code] == nil {
+				sourceMap[code] = make(map[string]string)
+				*order = append(*order, code)
+			}
+			for field := range entry {
+				if field != "code" {
+					sourceMap[code][field] = strings.TrimSpace(getString(entry, field))
+				}
+			}
+		}
+		return nil
 	}
 
+	if !strings.Contains(blob, "[sources]") {
+		blob = "[sources]\n" + blob
+	}
+	cfg, err := ini.Load([]byte(blob))
+	if err != nil {
+		return err
+	}
+	collectSourceFields(cfg.Section("sources"), sourceMap, order)
 	return nil
 }
 
-// GetSources 获取所有视频源
+// GetSources 获取所有视频源的副本
 func (sc *SourcesConfig) GetSources() []VideoSource {
-	return sc.sources
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	sources := make([]VideoSource, len(sc.sources))
+	copy(sources, sc.sources)
+	return sources
+}
+
+// GetEnabledSources 获取所有启用的视频源
+func (sc *SourcesConfig) GetEnabledSources() []VideoSource {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	var enabled []VideoSource
+	for _, source := range sc.sources {
+		if source.Enabled {
+			enabled = append(enabled, source)
+		}
+	}
+	return enabled
 }
 
 // GetSourceByCode 根据代码获取视频源
 func (sc *SourcesConfig) GetSourceByCode(code string) *VideoSource {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
 	for _, source := range sc.sources {
 		if source.Code == code {
 			return &source
@@ -165,10 +294,11 @@ func (sc *SourcesConfig) HandleSourcesAPI(w http.ResponseWriter, r *http.Request
 	}
 
 	// 返回JSON格式的视频源列表
+	sources := sc.GetSources()
 	response := map[string]interface{}{
 		"success": true,
-		"data":    sc.sources,
-		"count":   len(sc.sources),
+		"data":    sources,
+		"count":   len(sources),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -206,6 +336,8 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 	keyword := r.URL.Query().Get("keyword")
 	page := r.URL.Query().Get("page")
 	isLatest := r.URL.Query().Get("latest") == "true"
+	typeID := r.URL.Query().Get("type")
+	hours := r.URL.Query().Get("hours")
 
 	if sourceCode == "" {
 		w.Header().Set("Content-Type", "application/json")
@@ -218,8 +350,19 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// 如果不是获取最新推荐，则keyword是必需的
-	if !isLatest && keyword == "" {
+	if !isPositiveInt(typeID) || !isPositiveInt(hours) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid type or hours parameter",
+			"data":    []VideoItem{},
+		})
+		return
+	}
+
+	// 如果不是获取最新推荐或按分类/时间浏览，则keyword是必需的
+	if !isLatest && keyword == "" && typeID == "" && hours == "" {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -244,7 +387,8 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 	}
 
 	// 执行搜索
-	results, err := sc.searchSource(source, keyword, page)
+	query := SourceQuery{Keyword: keyword, Page: page, TypeID: typeID, Hours: hours}
+	results, err := sc.searchSource(r.Context(), source, query)
 	if err != nil {
 		log.Printf("❌ 搜索失败: %v [IP:%s]", err, utils.GetRequestIP(r))
 		w.Header().Set("Content-Type", "application/json")
@@ -258,11 +402,19 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 	}
 
 	// 返回搜索结果
+	items := results.List
+	if items == nil {
+		items = []VideoItem{}
+	}
 	response := SearchResponse{
-		Success: true,
-		Message: "搜索成功",
-		Data:    results,
-		Count:   len(results),
+		Success:   true,
+		Message:   "搜索成功",
+		Data:      items,
+		Count:     len(items),
+		Page:      results.Page,
+		PageCount: results.PageCount,
+		Limit:     results.Limit,
+		Total:     results.Total,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -270,6 +422,194 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 	log.Printf("✅ /api/source_search 请求 [IP:%s]", utils.GetRequestIP(r))
 }
 
+// HandleSourceCategoriesAPI 处理 /api/source_categories 接口，返回源的分类列表
+func (sc *SourcesConfig) HandleSourceCategoriesAPI(w http.ResponseWriter, r *http.Request) {
+	// 设置CORS头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理OPTIONS请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// 只允许GET请求
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Method not allowed",
+			"data":    []Category{},
+		})
+		return
+	}
+
+	sourceCode := r.URL.Query().Get("source")
+	if sourceCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Missing source parameter",
+			"data":    []Category{},
+		})
+		return
+	}
+
+	// 获取指定的视频源
+	source := sc.GetSourceByCode(sourceCode)
+	if source == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Source not found",
+			"data":    []Category{},
+		})
+		return
+	}
+
+	categories, err := sc.categoriesSource(r.Context(), source)
+	if err != nil {
+		log.Printf("❌ 获取分类失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Categories failed: " + err.Error(),
+			"data":    []Category{},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    categories,
+		"count":   len(categories),
+	})
+	log.Printf("✅ /api/source_categories 请求 (%s) [IP:%s]", sourceCode, utils.GetRequestIP(r))
+}
+
+// isPositiveInt 判断可选参数是否为空或正整数
+func isPositiveInt(value string) bool {
+	if value == "" {
+		return true
+	}
+	n, err := strconv.Atoi(value)
+	return err == nil && n > 0
+}
+
+// maxDetailIDs 单次详情请求允许的最大视频ID数量
+const maxDetailIDs = 50
+
+// HandleSourceDetailAPI 处理 /api/source_detail 接口，支持逗号分隔或重复的 id 参数批量获取
+func (sc *SourcesConfig) HandleSourceDetailAPI(w http.ResponseWriter, r *http.Request) {
+	// 设置CORS头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理OPTIONS请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// 只允许GET请求
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Method not allowed",
+			"data":    []VideoItem{},
+		})
+		return
+	}
+
+	// 获取查询参数
+	sourceCode := r.URL.Query().Get("source")
+	ids := parseVodIDs(r.URL.Query()["id"])
+
+	if sourceCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Missing source parameter",
+			"data":    []VideoItem{},
+		})
+		return
+	}
+
+	if len(ids) == 0 || len(ids) > maxDetailIDs {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Invalid id parameter (1-%d ids required)", maxDetailIDs),
+			"data":    []VideoItem{},
+		})
+		return
+	}
+
+	// 获取指定的视频源
+	source := sc.GetSourceByCode(sourceCode)
+	if source == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Source not found",
+			"data":    []VideoItem{},
+		})
+		return
+	}
+
+	// 获取详情
+	items, err := sc.detailSource(r.Context(), source, ids)
+	if err != nil {
+		log.Printf("❌ 获取详情失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Detail failed: " + err.Error(),
+			"data":    []VideoItem{},
+		})
+		return
+	}
+	if items == nil {
+		items = []VideoItem{}
+	}
+
+	response := SearchResponse{
+		Success: true,
+		Message: "获取成功",
+		Data:    items,
+		Count:   len(items),
+		Total:   len(items),
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("✅ /api/source_detail 请求 (%s, %d 个ID) [IP:%s]", sourceCode, len(ids), utils.GetRequestIP(r))
+}
+
+// parseVodIDs 解析 id 参数，支持逗号分隔和重复参数，去重并忽略非法ID
+func parseVodIDs(values []string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, id := range strings.Split(value, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] || strings.ContainsAny(id, "&?=/ ") {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 // HandleScorpioSourcesAPI 处理 /api/scorpio_sources 接口，返回 scorpio.json 中的全部内容
 func HandleScorpioSourcesAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -316,122 +656,64 @@ func HandleScorpioSourcesAPI(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// searchSource 搜索指定源
-func (sc *SourcesConfig) searchSource(source *VideoSource, keyword, page string) ([]VideoItem, error) {
-	// 构建请求URL
-	baseURL := source.URL
-	if !strings.HasSuffix(baseURL, "/") {
-		baseURL += "/"
-	}
-
-	// 构建查询参数
-	params := url.Values{}
-
-	// 统一使用 videolist 接口
-	params.Set("ac", "videolist")
-
-	// 判断是搜索还是获取最新推荐
-	if keyword == "" {
-		// 获取最新推荐 - 使用默认参数
-		params.Set("pg", "1") // 第一页
-	} else {
-		// 搜索 - 添加关键词
-		params.Set("wd", keyword)
-	}
-
-	if page != "" {
-		params.Set("pg", page)
-	}
-
-	requestURL := baseURL + "?" + params.Encode()
-
-	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// 创建请求
-	req, err := http.NewRequest("GET", requestURL, nil)
+// searchSource 搜索指定源，关键词为空时获取最新推荐
+func (sc *SourcesConfig) searchSource(ctx context.Context, source *VideoSource, query SourceQuery) (*VideoList, error) {
+	adapter, err := adapterFor(source)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+		return nil, err
 	}
 
-	// 设置请求头
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36")
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	// 发送请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %v", err)
+	if query.Keyword == "" {
+		return adapter.Latest(ctx, source, query)
 	}
-	defer resp.Body.Close()
+	return adapter.Search(ctx, source, query)
+}
 
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+// categoriesSource 获取指定源的分类列表
+func (sc *SourcesConfig) categoriesSource(ctx context.Context, source *VideoSource) ([]Category, error) {
+	adapter, err := adapterFor(source)
+	if err != nil {
+		return nil, err
 	}
+	return adapter.Categories(ctx, source)
+}
 
-	// 读取响应内容
-	body, err := io.ReadAll(resp.Body)
+// detailSource 按视频ID获取指定源的完整信息
+func (sc *SourcesConfig) detailSource(ctx context.Context, source *VideoSource, ids []string) ([]VideoItem, error) {
+	adapter, err := adapterFor(source)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
-	}
-
-	// 解析JSON响应
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("解析JSON失败: %v", err)
-	}
-
-	// 添加调试日志
-	log.Printf("🔍 API响应状态: 成功")
-
-	// 提取视频列表
-	var videos []VideoItem
-
-	// 尝试不同的数据结构
-	if list, ok := result["list"].([]interface{}); ok {
-		log.Printf("✅ 找到list字段，包含 %d 个视频", len(list))
-		for _, item := range list {
-			if videoMap, ok := item.(map[string]interface{}); ok {
-				video := VideoItem{
-					VodName:     getString(videoMap, "vod_name"),
-					VodPic:      getString(videoMap, "vod_pic"),
-					VodYear:     getString(videoMap, "vod_year"),
-					TypeName:    getString(videoMap, "type_name"),
-					VodScore:    getString(videoMap, "vod_score"),
-					VodContent:  getString(videoMap, "vod_content"),
-					VodActor:    getString(videoMap, "vod_actor"),
-					VodDirector: getString(videoMap, "vod_director"),
-					VodArea:     getString(videoMap, "vod_area"),
-					VodLang:     getString(videoMap, "vod_lang"),
-					VodTime:     getString(videoMap, "vod_time"),
-					VodRemarks:  getString(videoMap, "vod_remarks"),
-					VodPlayUrl:  getString(videoMap, "vod_play_url"),
-				}
-				videos = append(videos, video)
-			}
-		}
-	} else {
-		log.Printf("❌ 未找到list字段或格式不正确，result keys: %v", getMapKeys(result))
+		return nil, err
 	}
-
-	return videos, nil
+	return adapter.Detail(ctx, source, ids)
 }
 
-// getString 安全地从map中获取字符串值
+// getString 安全地从map中获取字符串值，数字和布尔值会转换为字符串
 func getString(m map[string]interface{}, key string) string {
-	if val, ok := m[key]; ok {
-		if str, ok := val.(string); ok {
-			return str
-		}
+	switch val := m[key].(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
 	}
 	return ""
 }
 
+// getInt 安全地从map中获取整数值，兼容字符串形式的数字
+func getInt(m map[string]interface{}, key string) int {
+	str := strings.TrimSpace(getString(m, key))
+	if n, err := strconv.Atoi(str); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(str, 64); err == nil {
+		return int(f)
+	}
+	return 0
+}
+
 // getMapKeys 获取map的所有键
 func getMapKeys(m map[string]interface{}) []string {
 	keys := make([]string, 0, len(m))
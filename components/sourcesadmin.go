@@ -0,0 +1,314 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// adminSourceFields 管理接口可修改的视频源字段，新增时按此顺序写入配置文件
+var adminSourceFields = []string{"name", "url", "is_default", "enabled", "type", "proxy"}
+
+// AdminSource 管理接口返回的视频源，包含上游代理以及是否保存在配置文件中
+type AdminSource struct {
+	VideoSource
+	Proxy    string `json:"proxy"`
+	Editable bool   `json:"editable"` // 来自 VASTVIDEO_SOURCES 的源不在配置文件中，无法修改
+}
+
+// sourcesAdminError 带 HTTP 状态码的管理接口错误
+type sourcesAdminError struct {
+	status  int
+	message string
+}
+
+func (e *sourcesAdminError) Error() string {
+	return e.message
+}
+
+func newSourcesAdminError(status int, format string, args ...interface{}) error {
+	return &sourcesAdminError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// HandleAdminSourcesAPI 处理 /api/admin/sources 接口，需要管理令牌：
+// GET 列出视频源；POST 新增（code、name、url，可选 type、proxy、is_default、enabled）；
+// PUT 修改 code 指定源的字段，action=reorder 时按 order=a,b,c 调整顺序；DELETE 删除 code 指定的源。
+// 修改写回配置文件（保留注释和顺序）后立即重新加载
+func (c *ConfigReloader) HandleAdminSourcesAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+AdminTokenHeader)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" && r.Method != "PUT" && r.Method != "DELETE" {
+		writeAdminError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !requireAdmin(w, r, c.Config()) {
+		return
+	}
+
+	message := "获取视频源成功"
+	if r.Method != "GET" {
+		var err error
+		if message, err = c.applySourcesRequest(r); err != nil {
+			status := http.StatusUnprocessableEntity
+			if adminErr, ok := err.(*sourcesAdminError); ok {
+				status = adminErr.status
+			}
+			writeAdminError(w, status, err.Error())
+			log.Printf("❌ /api/admin/sources %s 失败: %v [IP:%s]", r.Method, err, utils.GetRequestIP(r))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data":    c.adminSources(),
+	})
+	log.Printf("✅ /api/admin/sources %s 请求 [IP:%s]", r.Method, utils.GetRequestIP(r))
+}
+
+// applySourcesRequest 根据请求修改配置文件中的 [sources]，返回成功提示
+func (c *ConfigReloader) applySourcesRequest(r *http.Request) (string, error) {
+	code := strings.TrimSpace(r.FormValue("code"))
+
+	if r.Method == "PUT" && r.FormValue("action") == "reorder" {
+		var order []string
+		for _, value := range r.Form["order"] {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					order = append(order, item)
+				}
+			}
+		}
+		if len(order) == 0 {
+			return "", newSourcesAdminError(http.StatusBadRequest, "缺少 order 参数")
+		}
+		return "视频源顺序已更新", c.editSources(func(e *sourcesEditor) error {
+			return e.Reorder(order)
+		})
+	}
+
+	if code == "" {
+		return "", newSourcesAdminError(http.StatusBadRequest, "缺少 code 参数")
+	}
+	fields, err := sourceFieldsFromForm(r)
+	if err != nil {
+		return "", err
+	}
+	if sourceURL, ok := fields["url"]; ok {
+		if err := c.checkSourceURL(sourceURL); err != nil {
+			return "", err
+		}
+	}
+
+	switch r.Method {
+	case "POST":
+		if !sourceCodePattern.MatchString(code) {
+			return "", newSourcesAdminError(http.StatusBadRequest, "视频源代码 %q 只能包含字母、数字、下划线和连字符", code)
+		}
+		if fields["name"] == "" || fields["url"] == "" {
+			return "", newSourcesAdminError(http.StatusBadRequest, "新增视频源需要 name 和 url")
+		}
+		if _, ok := fields["is_default"]; !ok {
+			fields["is_default"] = "0"
+		}
+		if _, ok := fields["enabled"]; !ok {
+			fields["enabled"] = "1"
+		}
+		return "视频源已新增", c.editSources(func(e *sourcesEditor) error {
+			if e.Has(code) || c.sources.GetSourceByCode(code) != nil {
+				return newSourcesAdminError(http.StatusConflict, "视频源已存在: %s", code)
+			}
+			if err := clearOtherDefaults(e, code, fields); err != nil {
+				return err
+			}
+			return setSourceFields(e, code, fields)
+		})
+
+	case "PUT":
+		if len(fields) == 0 {
+			return "", newSourcesAdminError(http.StatusBadRequest, "没有需要修改的字段，支持 %s", strings.Join(adminSourceFields, "、"))
+		}
+		return "视频源已更新", c.editSources(func(e *sourcesEditor) error {
+			if err := c.requireFileSource(e, code); err != nil {
+				return err
+			}
+			if err := clearOtherDefaults(e, code, fields); err != nil {
+				return err
+			}
+			return setSourceFields(e, code, fields)
+		})
+
+	default:
+		return "视频源已删除", c.editSources(func(e *sourcesEditor) error {
+			if err := c.requireFileSource(e, code); err != nil {
+				return err
+			}
+			e.Remove(code)
+			return nil
+		})
+	}
+}
+
+// sourceFieldsFromForm 读取请求中出现的视频源字段，布尔值统一为 1/0
+func sourceFieldsFromForm(r *http.Request) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, field := range adminSourceFields {
+		if _, ok := r.Form[field]; !ok {
+			continue
+		}
+		value := strings.TrimSpace(r.Form.Get(field))
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, newSourcesAdminError(http.StatusBadRequest, "%s 不能包含换行", field)
+		}
+		switch field {
+		case "name", "url":
+			if value == "" {
+				return nil, newSourcesAdminError(http.StatusBadRequest, "%s 不能为空", field)
+			}
+		case "is_default", "enabled":
+			b, valid := parseSourceBool(value)
+			if !valid {
+				return nil, newSourcesAdminError(http.StatusBadRequest, "%s 需要 1/0 或 true/false，当前为 %q", field, value)
+			}
+			value = "0"
+			if b {
+				value = "1"
+			}
+		case "type":
+			value = strings.ToLower(value)
+		}
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// checkSourceURL 按代理访问策略检查视频源地址的协议、域名和解析出的 IP 地址
+func (c *ConfigReloader) checkSourceURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return newSourcesAdminError(http.StatusBadRequest, "url 无效: %v", err)
+	}
+	if err := currentProxyRuntime(c.Config()).policy.CheckDestination(u); err != nil {
+		return newSourcesAdminError(http.StatusBadRequest, "视频源地址不可用: %v", err)
+	}
+	return nil
+}
+
+// clearOtherDefaults 设为默认源时取消其他视频源的默认选中，与本次修改一起写回
+func clearOtherDefaults(e *sourcesEditor, code string, fields map[string]string) error {
+	if fields["is_default"] != "1" {
+		return nil
+	}
+	for _, other := range e.Codes() {
+		if isDefault, _ := parseSourceBool(e.Get(other, "is_default")); other != code && isDefault {
+			if err := e.Set(other, "is_default", "0"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setSourceFields 写入视频源字段，type、proxy 为空时删除该项以恢复默认值
+func setSourceFields(e *sourcesEditor, code string, fields map[string]string) error {
+	for _, field := range adminSourceFields {
+		value, ok := fields[field]
+		if !ok {
+			continue
+		}
+		if value == "" {
+			e.Unset(code, field)
+			continue
+		}
+		if err := e.Set(code, field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireFileSource 检查视频源是否在配置文件中
+func (c *ConfigReloader) requireFileSource(e *sourcesEditor, code string) error {
+	if e.Has(code) {
+		return nil
+	}
+	if c.sources.GetSourceByCode(code) != nil {
+		return newSourcesAdminError(http.StatusConflict, "视频源 %s 来自环境变量 %s，无法通过接口修改", code, utils.SourcesEnvVar)
+	}
+	return newSourcesAdminError(http.StatusNotFound, "视频源不存在: %s", code)
+}
+
+// editSources 修改配置文件的 [sources]：检查修改后的配置，原子写回文件并重新加载
+func (c *ConfigReloader) editSources(edit func(e *sourcesEditor) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" {
+		return newSourcesAdminError(http.StatusConflict, "当前使用内置配置，无法保存视频源修改，请通过 -config 指定配置文件")
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return newSourcesAdminError(http.StatusInternalServerError, "读取配置文件失败: %v", err)
+	}
+	editor := newSourcesEditor(data)
+	if err := edit(editor); err != nil {
+		return err
+	}
+
+	newData := editor.Bytes()
+	cfg, err := utils.LoadConfigFromData(newData)
+	if err != nil {
+		return err
+	}
+	if err := ValidateConfig(cfg, newData).Err(); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.path, newData); err != nil {
+		return newSourcesAdminError(http.StatusInternalServerError, "写入配置文件失败: %v", err)
+	}
+
+	result := ReloadResult{Time: time.Now().Unix(), Trigger: ReloadTriggerAdmin}
+	if err := c.reloadLocked(&result); err != nil {
+		result.Message = err.Error()
+		c.last = result
+		return newSourcesAdminError(http.StatusInternalServerError, "配置文件已保存，但重新加载失败: %v", err)
+	}
+	result.Success = true
+	result.Message = "视频源已保存并重新加载"
+	c.last = result
+	log.Printf("🔄 视频源已保存到 %s 并重新加载，共 %d 个源", c.path, result.Sources)
+	return nil
+}
+
+// adminSources 当前生效的视频源，并标记是否可以通过接口修改
+func (c *ConfigReloader) adminSources() []AdminSource {
+	editable := make(map[string]bool)
+	if c.path != "" {
+		if data, err := os.ReadFile(c.path); err == nil {
+			for _, code := range newSourcesEditor(data).Codes() {
+				editable[code] = true
+			}
+		}
+	}
+	sources := c.sources.GetSources()
+	list := make([]AdminSource, len(sources))
+	for i, source := range sources {
+		list[i] = AdminSource{VideoSource: source, Proxy: source.Proxy, Editable: editable[source.Code]}
+	}
+	return list
+}
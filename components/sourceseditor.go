@@ -0,0 +1,326 @@
+package components
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourcesEditor 按行编辑配置文件中的 [sources] 段，只改写视频源配置项所在的行，
+// 其余内容（注释、空行、其他配置段）保持原样
+type sourcesEditor struct {
+	lines       []string
+	newline     string
+	trailingEOL bool // 原文件是否以换行结尾
+}
+
+// newSourcesEditor 解析配置文件内容
+func newSourcesEditor(data []byte) *sourcesEditor {
+	text := string(data)
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	trailingEOL := strings.HasSuffix(text, "\n")
+	text = strings.TrimSuffix(text, "\n")
+	return &sourcesEditor{lines: strings.Split(text, "\n"), newline: newline, trailingEOL: trailingEOL}
+}
+
+// Bytes 编辑后的配置文件内容
+func (e *sourcesEditor) Bytes() []byte {
+	text := strings.Join(e.lines, e.newline)
+	if e.trailingEOL {
+		text += e.newline
+	}
+	return []byte(text)
+}
+
+// section 返回 [sources] 段标题所在行和段结束位置（下一个段标题或文件末尾），没有该段时 start 为 -1
+func (e *sourcesEditor) section() (start, end int) {
+	start = -1
+	for i, line := range e.lines {
+		name, ok := sectionHeader(line)
+		if !ok {
+			continue
+		}
+		if start >= 0 {
+			return start, i
+		}
+		if name == "sources" {
+			start = i
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(e.lines)
+}
+
+// sectionHeader 判断是否为段标题行并返回段名
+func sectionHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		return strings.TrimSpace(line[1 : len(line)-1]), true
+	}
+	return "", false
+}
+
+// sourceKey 解析 code.field = 值 格式的行，注释、空行和其他格式返回 false
+func sourceKey(line string) (code, field string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+	idx := strings.IndexAny(line, "=:")
+	if idx < 0 {
+		return "", "", false
+	}
+	// 与加载时一致，忽略代码或字段名无效的行
+	parts := strings.Split(strings.TrimSpace(line[:idx]), ".")
+	if len(parts) != 2 || parts[1] == "" || !sourceCodePattern.MatchString(parts[0]) {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// sourceValue 返回配置项的值，去掉包裹的引号和行内注释
+func sourceValue(line string) string {
+	idx := strings.IndexAny(line, "=:")
+	if idx < 0 {
+		return ""
+	}
+	value := strings.TrimSpace(line[idx+1:])
+	for _, quote := range []string{"`", `"`} {
+		if len(value) >= 2 && strings.HasPrefix(value, quote) && strings.HasSuffix(value, quote) {
+			return value[1 : len(value)-1]
+		}
+	}
+	if idx := strings.IndexAny(value, "#;"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx]) // 行内注释
+	}
+	return value
+}
+
+// Codes 按出现顺序返回 [sources] 段中的视频源代码
+func (e *sourcesEditor) Codes() []string {
+	start, end := e.section()
+	var codes []string
+	seen := make(map[string]bool)
+	for i := start + 1; start >= 0 && i < end; i++ {
+		if code, _, ok := sourceKey(e.lines[i]); ok && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// Has 判断 [sources] 段中是否存在该视频源
+func (e *sourcesEditor) Has(code string) bool {
+	for _, c := range e.Codes() {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Get 返回视频源字段最后一处定义的值，不存在时为空
+func (e *sourcesEditor) Get(code, field string) string {
+	start, end := e.section()
+	value := ""
+	for i := start + 1; start >= 0 && i < end; i++ {
+		if c, f, ok := sourceKey(e.lines[i]); ok && c == code && f == field {
+			value = sourceValue(e.lines[i])
+		}
+	}
+	return value
+}
+
+// Set 设置视频源字段：已有该字段时改写最后一处定义，否则插入到该源最后一个配置项之后；
+// 视频源不存在时在段末尾新增一组配置，以空行分隔
+func (e *sourcesEditor) Set(code, field, value string) error {
+	start, end := e.section()
+	if start < 0 {
+		return fmt.Errorf("配置文件中没有 [sources] 段")
+	}
+	line := code + "." + field + " = " + formatINIValue(value)
+
+	fieldLine, lastLine := -1, -1
+	for i := start + 1; i < end; i++ {
+		c, f, ok := sourceKey(e.lines[i])
+		if !ok || c != code {
+			continue
+		}
+		lastLine = i
+		if f == field {
+			fieldLine = i
+		}
+	}
+	switch {
+	case fieldLine >= 0:
+		e.lines[fieldLine] = line
+	case lastLine >= 0:
+		e.insert(lastLine+1, line)
+	default:
+		// 追加在段内最后一个非空行之后
+		at := end
+		for at > start+1 && strings.TrimSpace(e.lines[at-1]) == "" {
+			at--
+		}
+		if at > start+1 {
+			e.insert(at, "", line)
+		} else {
+			e.insert(at, line)
+		}
+	}
+	return nil
+}
+
+// Unset 删除视频源的某个字段
+func (e *sourcesEditor) Unset(code, field string) {
+	start, end := e.section()
+	for i := end - 1; i > start && start >= 0; i-- {
+		if c, f, ok := sourceKey(e.lines[i]); ok && c == code && f == field {
+			e.lines = append(e.lines[:i], e.lines[i+1:]...)
+		}
+	}
+}
+
+// Remove 删除视频源的全部配置项，删除后多余的连续空行合并为一行
+func (e *sourcesEditor) Remove(code string) {
+	start, end := e.section()
+	if start < 0 {
+		return
+	}
+	kept := make([]string, 0, len(e.lines))
+	kept = append(kept, e.lines[:start+1]...)
+	for i := start + 1; i < end; i++ {
+		line := e.lines[i]
+		if c, _, ok := sourceKey(line); ok && c == code {
+			continue
+		}
+		if strings.TrimSpace(line) == "" && len(kept) > start+1 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	// 删除段末尾的源后，分隔用的空行不再需要
+	for len(kept) > start+1 && trailingBlanks(kept) > trailingBlanks(e.lines[:end]) {
+		kept = kept[:len(kept)-1]
+	}
+	e.lines = append(kept, e.lines[end:]...)
+}
+
+// Reorder 调整视频源顺序：codes 中的源依次排在前面，其余源保持原有相对顺序。
+// 每个源从第一个到最后一个配置项为一个整体，原有位置依次放入新顺序的源，位置之间的注释和空行不变
+func (e *sourcesEditor) Reorder(codes []string) error {
+	start, end := e.section()
+	if start < 0 {
+		return fmt.Errorf("配置文件中没有 [sources] 段")
+	}
+
+	type block struct{ first, last int }
+	blocks := make(map[string]*block)
+	current := e.Codes()
+	for i := start + 1; i < end; i++ {
+		code, _, ok := sourceKey(e.lines[i])
+		if !ok {
+			continue
+		}
+		if b, exists := blocks[code]; exists {
+			b.last = i
+		} else {
+			blocks[code] = &block{first: i, last: i}
+		}
+	}
+	// 各源的配置项需要连续，交错时无法整体移动
+	for i := 1; i < len(current); i++ {
+		if blocks[current[i]].first < blocks[current[i-1]].last {
+			return fmt.Errorf("视频源 %s 与 %s 的配置项交错，无法调整顺序", current[i-1], current[i])
+		}
+	}
+
+	var order []string
+	listed := make(map[string]bool)
+	for _, code := range codes {
+		if _, ok := blocks[code]; !ok {
+			return fmt.Errorf("视频源不存在: %s", code)
+		}
+		if !listed[code] {
+			listed[code] = true
+			order = append(order, code)
+		}
+	}
+	for _, code := range current {
+		if !listed[code] {
+			order = append(order, code)
+		}
+	}
+
+	var lines []string
+	lines = append(lines, e.lines[:blocks[current[0]].first]...)
+	for i, code := range order {
+		b := blocks[code]
+		lines = append(lines, e.lines[b.first:b.last+1]...)
+		if i+1 < len(current) {
+			// 原位置之间的注释和空行
+			lines = append(lines, e.lines[blocks[current[i]].last+1:blocks[current[i+1]].first]...)
+		}
+	}
+	lines = append(lines, e.lines[blocks[current[len(current)-1]].last+1:]...)
+	e.lines = lines
+	return nil
+}
+
+// trailingBlanks 末尾连续空行的数量
+func trailingBlanks(lines []string) int {
+	n := 0
+	for n < len(lines) && strings.TrimSpace(lines[len(lines)-1-n]) == "" {
+		n++
+	}
+	return n
+}
+
+// insert 在 at 位置插入行
+func (e *sourcesEditor) insert(at int, lines ...string) {
+	e.lines = append(e.lines[:at], append(lines, e.lines[at:]...)...)
+}
+
+// formatINIValue 包含注释符号的值使用反引号包裹，避免被当作行内注释
+func formatINIValue(value string) string {
+	if strings.ContainsAny(value, "#;") || strings.TrimSpace(value) != value {
+		return "`" + value + "`"
+	}
+	return value
+}
+
+// writeFileAtomic 先写入同目录的临时文件再重命名，保留原文件权限
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,279 @@
+package components
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+// editorConfig 带注释、其他配置段和非 ASCII 名称的配置文件
+const editorConfig = `[server]
+port = 8228
+
+[sources]
+# 视频源配置
+# 格式: code.name = 名称, code.url = URL
+名称, code.url = URL, code.is_default = 是否默认(1/0)
+a.name = 资源 A
+a.url = https://a.example.com/api.php/provide/vod
+a.is_default = 1
+
+; b 使用 XML 协议
+b.name = ` + "`我的 #1`" + `
+b.url = https://b.example.com/api.php/provide/vod/at/xml
+b.type = maccms_xml
+
+c.name = 资源 C ; 行内注释
+c.url = https://c.example.com/api.php/provide/vod
+
+[logging]
+level = info
+`
+
+func TestSourcesEditorRoundTrip(t *testing.T) {
+	for _, data := range []string{
+		editorConfig,
+		strings.ReplaceAll(editorConfig, "\n", "\r\n"),
+		strings.TrimSuffix(editorConfig, "\n"),
+	} {
+		if got := string(newSourcesEditor([]byte(data)).Bytes()); got != data {
+			t.Errorf("未修改时内容应保持不变:\n%q\n实际:\n%q", data, got)
+		}
+	}
+}
+
+func TestSourcesEditorRead(t *testing.T) {
+	e := newSourcesEditor([]byte(editorConfig))
+	if got := strings.Join(e.Codes(), ","); got != "a,b,c" {
+		t.Errorf("Codes() = %s，说明行不应视为视频源", got)
+	}
+	if !e.Has("b") || e.Has("名称, code") || e.Has("server") {
+		t.Errorf("Has() 结果错误")
+	}
+	for _, tt := range []struct{ code, field, want string }{
+		{"a", "name", "资源 A"},
+		{"b", "name", "我的 #1"},
+		{"c", "name", "资源 C"},
+		{"a", "is_default", "1"},
+		{"b", "is_default", ""},
+	} {
+		if got := e.Get(tt.code, tt.field); got != tt.want {
+			t.Errorf("Get(%s, %s) = %q，期望 %q", tt.code, tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestSourcesEditorEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(e *sourcesEditor) error
+		want string // 期望的 [sources] 段内容（不含段标题）
+	}{
+		{
+			"修改已有字段",
+			func(e *sourcesEditor) error { return e.Set("c", "name", "新名称") },
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"a.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\n\n" +
+				"; b 使用 XML 协议\nb.name = `我的 #1`\nb.url = https://b.example.com/api.php/provide/vod/at/xml\nb.type = maccms_xml\n\n" +
+				"c.name = 新名称\nc.url = https://c.example.com/api.php/provide/vod\n\n",
+		},
+		{
+			"新增字段插入到该源之后",
+			func(e *sourcesEditor) error { return e.Set("a", "enabled", "0") },
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"a.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\na.enabled = 0\n\n" +
+				"; b 使用 XML 协议\nb.name = `我的 #1`\nb.url = https://b.example.com/api.php/provide/vod/at/xml\nb.type = maccms_xml\n\n" +
+				"c.name = 资源 C ; 行内注释\nc.url = https://c.example.com/api.php/provide/vod\n\n",
+		},
+		{
+			"新增视频源追加到段末尾",
+			func(e *sourcesEditor) error {
+				if err := e.Set("d", "name", "带;分号"); err != nil {
+					return err
+				}
+				return e.Set("d", "url", "https://d.example.com/")
+			},
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"a.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\n\n" +
+				"; b 使用 XML 协议\nb.name = `我的 #1`\nb.url = https://b.example.com/api.php/provide/vod/at/xml\nb.type = maccms_xml\n\n" +
+				"c.name = 资源 C ; 行内注释\nc.url = https://c.example.com/api.php/provide/vod\n\n" +
+				"d.name = `带;分号`\nd.url = https://d.example.com/\n\n",
+		},
+		{
+			"删除字段",
+			func(e *sourcesEditor) error { e.Unset("b", "type"); return nil },
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"a.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\n\n" +
+				"; b 使用 XML 协议\nb.name = `我的 #1`\nb.url = https://b.example.com/api.php/provide/vod/at/xml\n\n" +
+				"c.name = 资源 C ; 行内注释\nc.url = https://c.example.com/api.php/provide/vod\n\n",
+		},
+		{
+			"删除中间的源",
+			func(e *sourcesEditor) error { e.Remove("b"); return nil },
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"a.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\n\n" +
+				"; b 使用 XML 协议\n\n" +
+				"c.name = 资源 C ; 行内注释\nc.url = https://c.example.com/api.php/provide/vod\n\n",
+		},
+		{
+			"删除最后的源",
+			func(e *sourcesEditor) error { e.Remove("c"); return nil },
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"a.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\n\n" +
+				"; b 使用 XML 协议\nb.name = `我的 #1`\nb.url = https://b.example.com/api.php/provide/vod/at/xml\nb.type = maccms_xml\n\n",
+		},
+		{
+			"调整顺序",
+			func(e *sourcesEditor) error { return e.Reorder([]string{"c", "a"}) },
+			"# 视频源配置\n# 格式: code.name = 名称, code.url = URL\n名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+				"c.name = 资源 C ; 行内注释\nc.url = https://c.example.com/api.php/provide/vod\n\n" +
+				"; b 使用 XML 协议\na.name = 资源 A\na.url = https://a.example.com/api.php/provide/vod\na.is_default = 1\n\n" +
+				"b.name = `我的 #1`\nb.url = https://b.example.com/api.php/provide/vod/at/xml\nb.type = maccms_xml\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newSourcesEditor([]byte(editorConfig))
+			if err := tt.edit(e); err != nil {
+				t.Fatalf("编辑失败: %v", err)
+			}
+			want := "[server]\nport = 8228\n\n[sources]\n" + tt.want + "[logging]\nlevel = info\n"
+			if got := string(e.Bytes()); got != want {
+				t.Errorf("结果:\n%s\n期望:\n%s", got, want)
+			}
+
+			// 编辑后的内容仍能按原样解析
+			sc := NewSourcesConfig()
+			if err := sc.LoadFromConfigFile(e.Bytes()); err != nil {
+				t.Fatalf("加载编辑后的配置失败: %v", err)
+			}
+			var codes []string
+			for _, source := range sc.GetSources() {
+				codes = append(codes, source.Code)
+			}
+			if got := strings.Join(codes, ","); got != strings.Join(e.Codes(), ",") {
+				t.Errorf("加载的视频源 %s 与编辑器顺序 %v 不一致", got, e.Codes())
+			}
+			if source := sc.GetSourceByCode("b"); source != nil && source.Name != "我的 #1" {
+				t.Errorf("b.name = %q", source.Name)
+			}
+		})
+	}
+}
+
+func TestSourcesEditorLineEndings(t *testing.T) {
+	crlf := strings.ReplaceAll(editorConfig, "\n", "\r\n")
+	e := newSourcesEditor([]byte(crlf))
+	if err := e.Set("a", "enabled", "0"); err != nil {
+		t.Fatal(err)
+	}
+	got := string(e.Bytes())
+	if !strings.Contains(got, "a.is_default = 1\r\na.enabled = 0\r\n") || strings.Contains(strings.ReplaceAll(got, "\r\n", ""), "\n") {
+		t.Errorf("应保持 CRLF 换行:\n%q", got)
+	}
+
+	noEOL := strings.TrimSuffix(editorConfig, "\n")
+	e = newSourcesEditor([]byte(noEOL))
+	e.Remove("a")
+	if got := string(e.Bytes()); strings.HasSuffix(got, "\n") {
+		t.Errorf("原文件没有结尾换行时不应添加:\n%q", got)
+	}
+}
+
+func TestSourcesEditorReorderErrors(t *testing.T) {
+	e := newSourcesEditor([]byte(editorConfig))
+	if err := e.Reorder([]string{"x"}); err == nil {
+		t.Errorf("不存在的源应返回错误")
+	}
+	interleaved := "[sources]\na.name = A\nb.name = B\na.url = https://a.example.com/\nb.url = https://b.example.com/\n"
+	if err := newSourcesEditor([]byte(interleaved)).Reorder([]string{"b"}); err == nil {
+		t.Errorf("配置项交错时应返回错误")
+	}
+}
+
+// newTestSourcesAdmin 使用临时配置文件创建配置重载器
+func newTestSourcesAdmin(t *testing.T) (*ConfigReloader, string) {
+	t.Helper()
+	t.Setenv(utils.SourcesEnvVar, "")
+	t.Setenv("VASTVIDEO_ADMIN_TOKEN", "secret")
+	path := filepath.Join(t.TempDir(), "config.ini")
+	data := "[server]\nport = 8228\n\n[proxy]\nallowed_schemes = http, https\nblock_private = true\n\n[admin]\ntoken = secret\n\n[sources]\n" +
+		"a.name = A\na.url = https://a.example.com/\na.is_default = 1\n\n" +
+		"b.name = B\nb.url = https://b.example.com/\nb.is_default = 0\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := utils.LoadConfigFromData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ResolvePaths(path)
+	sources := NewSourcesConfig()
+	if err := sources.LoadFromConfigFile([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	ConfigureHTTP(cfg)
+	ConfigureProxyRuntime(cfg)
+	return NewConfigReloader(path, cfg, sources), path
+}
+
+func sourcesAdminRequest(c *ConfigReloader, method string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/admin/sources", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(AdminTokenHeader, "secret")
+	w := httptest.NewRecorder()
+	c.HandleAdminSourcesAPI(w, req)
+	return w
+}
+
+func TestAdminSourcesRejectsBlockedURL(t *testing.T) {
+	c, path := newTestSourcesAdmin(t)
+	before, _ := os.ReadFile(path)
+
+	for _, target := range []string{"ftp://a.example.com/", "http://127.0.0.1:8228/api", "http://10.0.0.1/", "http://localhost/"} {
+		w := sourcesAdminRequest(c, "POST", url.Values{"code": {"x"}, "name": {"X"}, "url": {target}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST url=%s 状态码 %d，期望 400: %s", target, w.Code, w.Body.String())
+		}
+		w = sourcesAdminRequest(c, "PUT", url.Values{"code": {"a"}, "url": {target}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("PUT url=%s 状态码 %d，期望 400: %s", target, w.Code, w.Body.String())
+		}
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("被拒绝的请求不应修改配置文件:\n%s", after)
+	}
+}
+
+func TestAdminSourcesSingleDefault(t *testing.T) {
+	c, path := newTestSourcesAdmin(t)
+
+	if w := sourcesAdminRequest(c, "PUT", url.Values{"code": {"b"}, "is_default": {"true"}}); w.Code != http.StatusOK {
+		t.Fatalf("PUT 失败 %d: %s", w.Code, w.Body.String())
+	}
+	data, _ := os.ReadFile(path)
+	e := newSourcesEditor(data)
+	if e.Get("a", "is_default") != "0" || e.Get("b", "is_default") != "1" {
+		t.Fatalf("设为默认后其他源应取消默认:\n%s", data)
+	}
+
+	w := sourcesAdminRequest(c, "POST", url.Values{"code": {"c"}, "name": {"C"}, "url": {"https://c.example.com/"}, "is_default": {"1"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST 失败 %d: %s", w.Code, w.Body.String())
+	}
+	var defaults []string
+	for _, source := range c.sources.GetSources() {
+		if source.IsDefault {
+			defaults = append(defaults, source.Code)
+		}
+	}
+	if strings.Join(defaults, ",") != "c" {
+		t.Errorf("重新加载后的默认源 %v，期望只有 c", defaults)
+	}
+}
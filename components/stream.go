@@ -0,0 +1,186 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// SourceStreamEvent 流式搜索中单个源的结果事件
+type SourceStreamEvent struct {
+	SourceSearchStatus
+	Data []VideoItem `json:"data"`
+}
+
+// StreamSummaryEvent 流式搜索结束时的汇总事件
+type StreamSummaryEvent struct {
+	Total   int   `json:"total"`   // 源总数
+	Success int   `json:"success"` // 成功的源数量
+	Failed  int   `json:"failed"`  // 失败或超时的源数量
+	Count   int   `json:"count"`   // 结果总数
+	Elapsed int64 `json:"elapsed"` // 总耗时（毫秒）
+}
+
+// streamWriter 以 SSE 或 NDJSON 格式写出事件
+type streamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ndjson  bool
+}
+
+// newStreamWriter 根据 format 参数或 Accept 头选择输出格式并写出响应头
+func newStreamWriter(w http.ResponseWriter, r *http.Request) (*streamWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("响应不支持流式输出")
+	}
+
+	format := r.URL.Query().Get("format")
+	ndjson := format == "ndjson" || (format == "" && strings.Contains(r.Header.Get("Accept"), "application/x-ndjson"))
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Header().Set("Connection", "keep-alive")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &streamWriter{w: w, flusher: flusher, ndjson: ndjson}, nil
+}
+
+// Send 写出一个事件并立即刷新
+func (sw *streamWriter) Send(event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if sw.ndjson {
+		line, err := json.Marshal(struct {
+			Event string          `json:"event"`
+			Data  json.RawMessage `json:"data"`
+		}{event, data})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(sw.w, "%s\n", line)
+		if err != nil {
+			return err
+		}
+	} else {
+		if _, err := fmt.Fprintf(sw.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+	}
+	sw.flusher.Flush()
+	return nil
+}
+
+// HandleSourceSearchStreamAPI 处理 /api/source_search_stream 接口，每个源返回后立即推送结果
+func (sc *SourcesConfig) HandleSourceSearchStreamAPI(w http.ResponseWriter, r *http.Request) {
+	// 设置CORS头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理OPTIONS请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 只允许GET请求
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 获取查询参数
+	keyword := r.URL.Query().Get("keyword")
+	page := r.URL.Query().Get("page")
+	isLatest := r.URL.Query().Get("latest") == "true"
+	hours := r.URL.Query().Get("hours")
+	timeout := parseSourceTimeout(r.URL.Query().Get("timeout"))
+
+	if !isPositiveInt(hours) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid hours parameter",
+		})
+		return
+	}
+
+	if !isLatest && keyword == "" && hours == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Missing keyword parameter",
+		})
+		return
+	}
+	if isLatest {
+		keyword = ""
+	}
+
+	sources := sc.selectSources(r.URL.Query().Get("sources"))
+	if len(sources) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "No available sources",
+		})
+		return
+	}
+
+	sw, err := newStreamWriter(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 客户端断开时 r.Context() 被取消，所有未完成的源请求随之终止
+	ctx := r.Context()
+	start := time.Now()
+	summary := StreamSummaryEvent{Total: len(sources)}
+
+	for result := range sc.fanOutSearch(ctx, sources, SourceQuery{Keyword: keyword, Page: page, Hours: hours}, timeout) {
+		if result.Status.Status == "ok" {
+			summary.Success++
+			summary.Count += len(result.Items)
+		} else {
+			summary.Failed++
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+
+		items := result.Items
+		if items == nil {
+			items = []VideoItem{}
+		}
+		if err := sw.Send("source", SourceStreamEvent{SourceSearchStatus: result.Status, Data: items}); err != nil {
+			log.Printf("⚠️ 流式推送失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		}
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("📴 客户端已断开，流式搜索终止 [IP:%s]", utils.GetRequestIP(r))
+		return
+	}
+
+	summary.Elapsed = time.Since(start).Milliseconds()
+	sw.Send("summary", summary)
+	log.Printf("✅ /api/source_search_stream 请求 (%d/%d 个源成功, %d 个结果) [IP:%s]",
+		summary.Success, summary.Total, summary.Count, utils.GetRequestIP(r))
+}
@@ -0,0 +1,179 @@
+package components
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"vastproxy-go/utils"
+
+	"gopkg.in/ini.v1"
+)
+
+// sourceCodePattern 视频源代码允许的字符
+var sourceCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// 视频源支持的字段
+var sourceFields = map[string]bool{"name": true, "url": true, "is_default": true, "enabled": true, "type": true, "proxy": true}
+
+// ValidateConfig 检查完整的配置：通用配置项、上游代理和 [sources] 视频源（含 VASTVIDEO_SOURCES）
+func ValidateConfig(cfg *utils.Config, configData []byte) utils.ConfigIssues {
+	issues := utils.ValidateConfig(cfg, configData)
+	if proxy := strings.TrimSpace(cfg.Proxy.UpstreamProxy); proxy != "" && proxy != UpstreamDirect {
+		if _, err := parseUpstreamProxy(proxy); err != nil {
+			issues = append(issues, utils.ConfigIssue{Key: "proxy.upstream_proxy", Message: err.Error()})
+		}
+	}
+	return append(issues, ValidateSources(configData)...)
+}
+
+// ValidateSources 检查 [sources]：键名格式、重复定义、必需字段、地址协议、类型、上游代理、布尔值和默认源冲突；
+// 键名格式不正确的项在加载时跳过，它与默认源冲突只在 -check-config 时视为错误，以兼容旧版配置文件
+func ValidateSources(configData []byte) utils.ConfigIssues {
+	var issues utils.ConfigIssues
+	add := func(key, format string, args ...interface{}) {
+		issues = append(issues, utils.ConfigIssue{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(key, format string, args ...interface{}) {
+		issues = append(issues, utils.ConfigIssue{Key: key, Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+	strict := func(key, format string, args ...interface{}) {
+		issues = append(issues, utils.ConfigIssue{Key: key, Message: fmt.Sprintf(format, args...) + "，该项已跳过", Warning: true, Strict: true})
+	}
+
+	// 允许重复键以便发现同一项的多次定义
+	file, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, configData)
+	if err != nil {
+		return utils.ConfigIssues{{Message: fmt.Sprintf("解析配置文件失败: %v", err)}}
+	}
+	if !file.HasSection("sources") {
+		return utils.ConfigIssues{{Key: "[sources]", Message: "配置文件中没有 [sources] 段"}}
+	}
+	section := file.Section("sources")
+
+	sourceMap := make(map[string]map[string]string)
+	codesByLower := make(map[string]string)
+	for _, key := range section.Keys() {
+		keyName := "sources." + key.Name()
+		parts := strings.Split(key.Name(), ".")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			strict(keyName, "格式应为 <代码>.<字段>，例如 bfzy.url")
+			continue
+		}
+		code, field := parts[0], parts[1]
+		if !sourceCodePattern.MatchString(code) {
+			strict(keyName, "视频源代码 %q 只能包含字母、数字、下划线和连字符", code)
+			continue
+		}
+		if !sourceFields[field] {
+			warn(keyName, "未知的字段 %q，支持 name、url、is_default、enabled、type、proxy", field)
+		}
+		values := key.ValueWithShadows()
+		if len(values) > 1 {
+			add(keyName, "重复定义 %d 次（%s），仅最后一个生效", len(values), strings.Join(values, " / "))
+		}
+		if other, ok := codesByLower[strings.ToLower(code)]; ok && other != code {
+			add(keyName, "视频源代码 %q 与 %q 仅大小写不同", code, other)
+			continue
+		}
+		codesByLower[strings.ToLower(code)] = code
+
+		if sourceMap[code] == nil {
+			sourceMap[code] = make(map[string]string)
+		}
+		sourceMap[code][field] = strings.TrimSpace(values[len(values)-1])
+	}
+
+	if blob := strings.TrimSpace(os.Getenv(utils.SourcesEnvVar)); blob != "" {
+		var order []string
+		if err := collectEnvSources(blob, sourceMap, &order); err != nil {
+			add(utils.SourcesEnvVar, "%v", err)
+		}
+	}
+
+	codes := make([]string, 0, len(sourceMap))
+	for code := range sourceMap {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	enabledCount := 0
+	var defaults []string
+	for _, code := range codes {
+		fields := sourceMap[code]
+		prefix := "sources." + code + "."
+
+		if fields["name"] == "" {
+			add(prefix+"name", "缺少视频源名称，该源不会加载")
+		}
+		if fields["url"] == "" {
+			add(prefix+"url", "缺少视频源地址，该源不会加载")
+		} else if u, err := url.Parse(fields["url"]); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(prefix+"url", "需要 http(s) 地址，当前为 %q", fields["url"])
+		}
+
+		isDefault, enabled := false, true
+		for _, field := range []string{"is_default", "enabled"} {
+			value, ok := fields[field]
+			if !ok {
+				continue
+			}
+			b, valid := parseSourceBool(value)
+			if !valid {
+				add(prefix+field, "需要 1/0 或 true/false，当前为 %q", value)
+			}
+			if field == "is_default" {
+				isDefault = b
+			} else {
+				enabled = b
+			}
+		}
+		if isDefault && !enabled {
+			warn(prefix+"is_default", "视频源已禁用 (enabled = 0)，默认选中不会生效")
+		}
+		if enabled {
+			enabledCount++
+			if isDefault {
+				defaults = append(defaults, code)
+			}
+		}
+
+		if sourceType := fields["type"]; sourceType != "" {
+			if _, err := GetSourceAdapter(sourceType); err != nil {
+				add(prefix+"type", "%v", err)
+			}
+		}
+		if proxy := fields["proxy"]; proxy != "" && proxy != UpstreamDirect {
+			if _, err := parseUpstreamProxy(proxy); err != nil {
+				add(prefix+"proxy", "%v", err)
+			}
+		}
+	}
+	if len(defaults) > 1 {
+		// 旧版配置文件默认选中了多个源，启动时只警告
+		issues = append(issues, utils.ConfigIssue{
+			Key:     "[sources]",
+			Message: fmt.Sprintf("%d 个启用的视频源同时设为默认 (%s)，只能有一个", len(defaults), strings.Join(defaults, ", ")),
+			Warning: true,
+			Strict:  true,
+		})
+	}
+	if enabledCount == 0 {
+		warn("[sources]", "没有启用的视频源")
+	}
+	return issues
+}
+
+// parseSourceBool 解析视频源的布尔字段，与加载时一致只认 1/true 为真
+func parseSourceBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "1", "true":
+		return true, true
+	case "0", "false":
+		return false, true
+	}
+	return false, false
+}
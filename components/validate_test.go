@@ -0,0 +1,158 @@
+package components
+
+import (
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+// findIssue 按配置项查找检查结果
+func findIssue(issues utils.ConfigIssues, key string) (utils.ConfigIssue, bool) {
+	for _, issue := range issues {
+		if issue.Key == key {
+			return issue, true
+		}
+	}
+	return utils.ConfigIssue{}, false
+}
+
+func TestValidateSources(t *testing.T) {
+	const valid = "a.name = A\na.url = https://a.example.com/api.php/provide/vod\n"
+
+	tests := []struct {
+		name    string
+		sources string
+		key     string // 期望出现的问题，为空时不应有任何问题
+		warning bool
+		strict  bool
+	}{
+		{"有效", valid + "a.is_default = 1\n", "", false, false},
+		{"旧版说明行", valid + "名称, code.url = URL, code.is_default = 是否默认(1/0)\n", "sources.名称, code.url", true, true},
+		{"缺少字段名", valid + "b = 1\n", "sources.b", true, true},
+		{"多级键名", valid + "b.url.x = 1\n", "sources.b.url.x", true, true},
+		{"未知字段", valid + "a.weight = 1\n", "sources.a.weight", true, false},
+		{"重复定义", valid + "a.url = https://b.example.com/\n", "sources.a.url", false, false},
+		{"大小写冲突", valid + "A.name = B\n", "sources.A.name", false, false},
+		{"缺少名称", "a.url = https://a.example.com/\n", "sources.a.name", false, false},
+		{"缺少地址", "a.name = A\n", "sources.a.url", false, false},
+		{"非 http 地址", "a.name = A\na.url = ftp://a.example.com/\n", "sources.a.url", false, false},
+		{"无效布尔值", valid + "a.is_default = yes\n", "sources.a.is_default", false, false},
+		{"不支持的类型", valid + "a.type = rss\n", "sources.a.type", false, false},
+		{"无效上游代理", valid + "a.proxy = ftp://127.0.0.1:21\n", "sources.a.proxy", false, false},
+		{"禁用的默认源", valid + "a.is_default = 1\na.enabled = 0\nb.name = B\nb.url = https://b.example.com/\n", "sources.a.is_default", true, false},
+		{"没有启用的源", valid + "a.enabled = 0\n", "[sources]", true, false},
+		{
+			"多个默认源",
+			valid + "a.is_default = 1\nb.name = B\nb.url = https://b.example.com/\nb.is_default = true\n",
+			"[sources]", true, true,
+		},
+		{
+			"禁用的源不计入默认源冲突",
+			valid + "a.is_default = 1\nb.name = B\nb.url = https://b.example.com/\nb.is_default = 1\nb.enabled = 0\n",
+			"sources.b.is_default", true, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(utils.SourcesEnvVar, "")
+			issues := ValidateSources([]byte("[sources]\n" + tt.sources))
+			if tt.key == "" {
+				if len(issues) > 0 {
+					t.Fatalf("不应有问题，实际 %v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 {
+				t.Fatalf("期望 1 个问题，实际 %v", issues)
+			}
+			issue, ok := findIssue(issues, tt.key)
+			if !ok {
+				t.Fatalf("缺少 %s 的问题，实际 %v", tt.key, issues)
+			}
+			if issue.Warning != tt.warning || issue.Strict != tt.strict {
+				t.Errorf("%s: Warning=%v Strict=%v，期望 Warning=%v Strict=%v", issue, issue.Warning, issue.Strict, tt.warning, tt.strict)
+			}
+		})
+	}
+}
+
+func TestValidateSourcesMissingSection(t *testing.T) {
+	issues := ValidateSources([]byte("[server]\nport = 8228\n"))
+	if _, ok := findIssue(issues, "[sources]"); !ok || issues.Err() == nil {
+		t.Fatalf("缺少 [sources] 段应为错误，实际 %v", issues)
+	}
+}
+
+func TestValidateSourcesEnv(t *testing.T) {
+	t.Setenv(utils.SourcesEnvVar, `[{"code":"b","url":"https://b.example.com/"}]`)
+	issues := ValidateSources([]byte("[sources]\na.name = A\na.url = https://a.example.com/\n"))
+	if _, ok := findIssue(issues, "sources.b.name"); !ok {
+		t.Fatalf("环境变量中的视频源应参与检查，实际 %v", issues)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	const sources = "[sources]\na.name = A\na.url = https://a.example.com/\n"
+
+	tests := []struct {
+		name   string
+		config string
+		key    string
+	}{
+		{"端口越界", "[server]\nport = 99999\n", "server.port"},
+		{"未知配置项", "[features]\ndouban_apii = true\n", "features.douban_apii"},
+		{"布尔值无效", "[proxy]\nblock_private = maybe\n", "proxy.block_private"},
+		{"上游代理无效", "[proxy]\nupstream_proxy = ftp://127.0.0.1:21\n", "proxy.upstream_proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(utils.SourcesEnvVar, "")
+			data := []byte(tt.config + sources)
+			cfg, err := utils.LoadConfigFromData(data)
+			if err != nil {
+				t.Fatalf("加载配置失败: %v", err)
+			}
+			issues := ValidateConfig(cfg, data)
+			issue, ok := findIssue(issues, tt.key)
+			if !ok {
+				t.Fatalf("缺少 %s 的问题，实际 %v", tt.key, issues)
+			}
+			if issue.Warning || issues.Err() == nil {
+				t.Errorf("%s 应为错误", issue)
+			}
+		})
+	}
+}
+
+func TestConfigIssuesStrict(t *testing.T) {
+	issues := utils.ConfigIssues{
+		{Key: "a", Message: "警告", Warning: true},
+		{Key: "b", Message: "跳过", Warning: true, Strict: true},
+	}
+	if issues.Err() != nil {
+		t.Fatalf("启动时 Strict 问题不应为错误")
+	}
+	strict := issues.Strict()
+	if errs := strict.Errors(); len(errs) != 1 || errs[0].Key != "b" {
+		t.Fatalf("-check-config 时应只有 b 为错误，实际 %v", errs)
+	}
+	if !issues[1].Warning {
+		t.Errorf("Strict 不应修改原检查结果")
+	}
+}
+
+func TestLoadSourcesSkipsMalformedKeys(t *testing.T) {
+	t.Setenv(utils.SourcesEnvVar, "")
+	sc := NewSourcesConfig()
+	data := "[sources]\n" +
+		"名称, code.url = URL, code.is_default = 是否默认(1/0)\n" +
+		"bad code.name = B\nbad code.url = https://b.example.com/\n" +
+		"a.name = A\na.url = https://a.example.com/\n"
+	if err := sc.LoadFromConfigFile([]byte(data)); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	sources := sc.GetSources()
+	if len(sources) != 1 || sources[0].Code != "a" {
+		t.Fatalf("应只加载 a，实际 %+v", sources)
+	}
+}
@@ -2,6 +2,9 @@ module vastproxy-go
 
 go 1.21
 
-require gopkg.in/ini.v1 v1.67.0
+require (
+	golang.org/x/text v0.14.0
+	gopkg.in/ini.v1 v1.67.0
+)
 
 require github.com/stretchr/testify v1.10.0 // indirect
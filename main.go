@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"flag"
@@ -70,9 +71,19 @@ func SaveScorpioSources(sources []*ScorpioSource) error {
 
 // 检查单个资源，返回是否可用、消息、JSON内容、响应时间（毫秒）
 func CheckSourceAPIWithBody(api string) (bool, string, interface{}, int64) {
+	return checkSourceAPIContext(context.Background(), api)
+}
+
+// checkSourceAPIContext 带上下文的资源检查，上下文取消时立即返回
+func checkSourceAPIContext(ctx context.Context, api string) (bool, string, interface{}, int64) {
 	start := time.Now()
-	client := &http.Client{Timeout: 15 * time.Second} // 增加最大等待时间
-	resp, err := client.Get(api)
+	req, err := http.NewRequestWithContext(ctx, "GET", api, nil)
+	if err != nil {
+		return false, err.Error(), nil, 0
+	}
+	req.Header.Set("User-Agent", components.UserAgent())
+	client := components.NewHTTPClient("", 15*time.Second) // 增加最大等待时间
+	resp, err := client.Do(req)
 	cost := time.Since(start).Milliseconds()
 	if err != nil {
 		return false, err.Error(), nil, cost
@@ -107,8 +118,13 @@ func mustJson(v interface{}) string {
 	return string(b)
 }
 
-// SSE流接口
+// SSE流接口：并发检测所有资源，每个检测完成后推送一条事件，全部完成后推送 done 事件
 func checkSourcesStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -116,14 +132,24 @@ func checkSourcesStreamHandler(w http.ResponseWriter, r *http.Request) {
 	sources, err := LoadScorpioSources()
 	if err != nil {
 		fmt.Fprintf(w, "data: {\"msg\":\"读取scorpio.json失败\"}\n\n")
-		w.(http.Flusher).Flush()
+		flusher.Flush()
 		return
 	}
 
+	// 客户端断开时取消所有检测
+	ctx := r.Context()
+	events := make(chan map[string]interface{}, len(sources))
+
+	var wg sync.WaitGroup
 	var mu sync.Mutex
 	for i, src := range sources {
+		wg.Add(1)
 		go func(idx int, s *ScorpioSource) {
-			isValid, msg, result, cost := CheckSourceAPIWithBody(s.API)
+			defer wg.Done()
+			isValid, msg, result, cost := checkSourceAPIContext(ctx, s.API)
+			if ctx.Err() != nil {
+				return
+			}
 			t := time.Now().Unix()
 			// 响应时间评级
 			level := "快"
@@ -135,7 +161,6 @@ func checkSourcesStreamHandler(w http.ResponseWriter, r *http.Request) {
 			mu.Lock()
 			s.LastCheckTime = t
 			s.IsValid = &isValid
-			SaveScorpioSources(sources)
 			mu.Unlock()
 			res := map[string]interface{}{
 				"index":           idx,
@@ -150,14 +175,35 @@ func checkSourcesStreamHandler(w http.ResponseWriter, r *http.Request) {
 			if isValid && result != nil {
 				res["result_json"] = result
 			}
-			b, _ := json.Marshal(res)
-			fmt.Fprintf(w, "data: %s\n\n", b)
-			w.(http.Flusher).Flush()
+			events <- res
 		}(i, src)
 	}
 
-	// 等待所有goroutine完成
-	time.Sleep(time.Duration(len(sources)) * 16 * time.Second)
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	// 由当前 goroutine 统一写出，避免并发写 ResponseWriter
+	checked := 0
+	for res := range events {
+		checked++
+		b, _ := json.Marshal(res)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		flusher.Flush()
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("📴 客户端已断开，资源检测终止 [IP:%s]", utils.GetRequestIP(r))
+		return
+	}
+
+	// 全部检测完成后统一保存
+	if err := SaveScorpioSources(sources); err != nil {
+		log.Printf("⚠️ 保存scorpio.json失败: %v", err)
+	}
+	fmt.Fprintf(w, "event: done\ndata: {\"total\":%d,\"checked\":%d}\n\n", len(sources), checked)
+	flusher.Flush()
 }
 
 // 检查单个资源API（前端逐个调用）
@@ -205,28 +251,84 @@ func HandleCheckSourceAPI(w http.ResponseWriter, r *http.Request) {
 
 var GlobalConfig *utils.Config
 
+// ConfigPath 实际加载的配置文件路径，使用内置配置时为空
+var ConfigPath string
+
+// 配置文件变化的检查间隔
+const configWatchInterval = 2 * time.Second
+
+// configReloader 持有热重载后当前生效的配置；GlobalConfig 仅用于监听地址、日志等启动时应用的设置
+var configReloader *components.ConfigReloader
+
+// currentConfig 当前生效的配置
+func currentConfig() *utils.Config {
+	return configReloader.Config()
+}
+
+// withFeature 按当前配置判断功能是否启用，未启用时返回 404
+func withFeature(enabled func(cfg *utils.Config) bool, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !enabled(currentConfig()) {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func proxyServiceEnabled(cfg *utils.Config) bool { return cfg.Features.ProxyService }
+func healthCheckEnabled(cfg *utils.Config) bool  { return cfg.Features.HealthCheck }
+func infoPageEnabled(cfg *utils.Config) bool     { return cfg.Features.InfoPage }
+func doubanAPIEnabled(cfg *utils.Config) bool    { return cfg.Features.DoubanAPI }
+func downloadEnabled(cfg *utils.Config) bool     { return cfg.Download.Enabled }
+
+// downloadManager 离线下载管理器，[download] 启用后首次使用时创建
+var downloadManager struct {
+	sync.Mutex
+	manager *components.DownloadManager
+}
+
+// downloadManagerFor 获取离线下载管理器，尚未创建时按当前配置创建
+func downloadManagerFor(sourcesConfig *components.SourcesConfig) (*components.DownloadManager, error) {
+	downloadManager.Lock()
+	defer downloadManager.Unlock()
+	if downloadManager.manager == nil {
+		manager, err := components.NewDownloadManager(currentConfig, sourcesConfig)
+		if err != nil {
+			return nil, err
+		}
+		downloadManager.manager = manager
+	}
+	return downloadManager.manager, nil
+}
+
 func main() {
+	// 定义命令行参数，需在加载配置前解析以确定配置文件
+	var (
+		configFile = flag.String("config", "", "配置文件路径（也可通过 VASTVIDEO_CONFIG 环境变量指定）")
+		port       = flag.String("port", "", "服务端口（默认使用配置文件中的 port）")
+		check      = flag.Bool("check-config", false, "检查配置文件后退出，存在错误时退出码为 1")
+	)
+	flag.Parse()
+
+	if *check {
+		os.Exit(runConfigCheck(*configFile))
+	}
+
 	// 加载配置文件
-	if err := LoadConfig(); err != nil {
+	configData, err := LoadConfig(*configFile)
+	if err != nil {
 		log.Fatalf("❌ 加载配置文件失败: %v", err)
 	}
+	if *port == "" {
+		*port = GlobalConfig.Server.Port
+	}
 
 	// 初始化视频源配置
 	sourcesConfig := components.NewSourcesConfig()
-	configData, err := ConfigContent.ReadFile("config/config.ini")
-	if err != nil {
-		log.Fatalf("❌ 读取配置文件失败: %v", err)
-	}
 	if err := sourcesConfig.LoadFromConfigFile(configData); err != nil {
 		log.Fatalf("❌ 加载视频源配置失败: %v", err)
 	}
-	log.Printf("✅ 视频源配置加载成功，共 %d 个源", len(sourcesConfig.GetSources()))
-
-	// 定义命令行参数
-	var (
-		port = flag.String("port", GlobalConfig.Server.Port, "服务端口")
-	)
-	flag.Parse()
 
 	// 设置日志输出
 	var outputs []io.Writer
@@ -245,44 +347,99 @@ func main() {
 		log.SetOutput(io.MultiWriter(outputs...))
 	}
 
+	if ConfigPath != "" {
+		log.Printf("📄 使用配置文件: %s", ConfigPath)
+	} else {
+		log.Printf("📄 未找到外部配置文件，使用内置配置")
+	}
+	log.Printf("✅ 视频源配置加载成功，共 %d 个源", len(sourcesConfig.GetSources()))
+
 	// 检查并处理端口占用
 	log.Printf("🔍 检查端口 %s 是否可用...", *port)
 	if err := checkAndKillPortProcess(*port); err != nil {
 		log.Fatalf("❌ 端口检查失败: %v", err)
 	}
 
+	// 初始化出站HTTP设置（连接池、User-Agent、重定向次数、上游代理）
+	components.ConfigureHTTP(GlobalConfig)
+
+	// 初始化代理访问策略、广告过滤、缓存与预取器
+	components.ConfigureProxyRuntime(GlobalConfig)
+
+	// 初始化代理链接签名器，首次启动时生成密钥
+	if err := components.ConfigureProxySigner(GlobalConfig); err != nil {
+		if GlobalConfig.Proxy.RequireSignature {
+			log.Fatalf("❌ 加载代理签名密钥失败: %v", err)
+		}
+		log.Printf("⚠️ 加载代理签名密钥失败，播放地址将不签名: %v", err)
+	}
+
+	// 配置热重载：收到 SIGHUP 或配置文件变化时重新加载，路由按当前配置判断功能是否启用
+	configReloader = components.NewConfigReloader(ConfigPath, GlobalConfig, sourcesConfig)
+	configReloader.Watch(configWatchInterval)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			configReloader.Reload(components.ReloadTriggerSignal)
+		}
+	}()
+
 	// 注册路由
-	if GlobalConfig.Features.ProxyService {
-		http.HandleFunc("/proxy", func(w http.ResponseWriter, r *http.Request) {
-			components.ProxyHandler(w, r, GlobalConfig)
+	http.HandleFunc("/proxy", withFeature(proxyServiceEnabled, func(w http.ResponseWriter, r *http.Request) {
+		components.ProxyHandler(w, r, currentConfig())
+	}))
+	http.HandleFunc("/api/download", withFeature(proxyServiceEnabled, func(w http.ResponseWriter, r *http.Request) {
+		components.DownloadHandler(w, r, currentConfig())
+	}))
+	http.HandleFunc("/api/cache_stats", withFeature(proxyServiceEnabled, func(w http.ResponseWriter, r *http.Request) {
+		components.CacheStatsHandler(w, r, currentConfig())
+	}))
+	downloadRoute := func(handle func(m *components.DownloadManager, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+		return withFeature(downloadEnabled, func(w http.ResponseWriter, r *http.Request) {
+			manager, err := downloadManagerFor(sourcesConfig)
+			if err != nil {
+				http.Error(w, "离线下载初始化失败: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			handle(manager, w, r)
 		})
 	}
-	if GlobalConfig.Features.HealthCheck {
-		http.HandleFunc("/health", healthHandler)
-	}
-	if GlobalConfig.Features.InfoPage {
-		http.HandleFunc("/info", infoHandler)
-		http.HandleFunc("/mobile", mobileHandler)
-		http.HandleFunc("/about", aboutHandler)
-		http.HandleFunc("/about.html", aboutHandler)
-		http.HandleFunc("/", indexHandler)
-	}
-	if GlobalConfig.Features.DoubanAPI {
-		http.HandleFunc("/douban", func(w http.ResponseWriter, r *http.Request) {
-			components.DoubanHandler(w, r, GlobalConfig)
-		})
+	http.HandleFunc("/api/downloads", downloadRoute((*components.DownloadManager).HandleDownloadsAPI))
+	http.HandleFunc("/api/downloads/events", downloadRoute((*components.DownloadManager).HandleDownloadEventsAPI))
+	http.HandleFunc("/api/downloads/file", downloadRoute((*components.DownloadManager).HandleDownloadFileAPI))
+	if GlobalConfig.Download.Enabled {
+		// 启动时继续之前未完成的下载任务
+		if _, err := downloadManagerFor(sourcesConfig); err != nil {
+			log.Printf("⚠️ 离线下载初始化失败: %v", err)
+		}
 	}
+	http.HandleFunc("/health", withFeature(healthCheckEnabled, healthHandler))
+	http.HandleFunc("/info", withFeature(infoPageEnabled, infoHandler))
+	http.HandleFunc("/mobile", withFeature(infoPageEnabled, mobileHandler))
+	http.HandleFunc("/about", withFeature(infoPageEnabled, aboutHandler))
+	http.HandleFunc("/about.html", withFeature(infoPageEnabled, aboutHandler))
+	http.HandleFunc("/", withFeature(infoPageEnabled, indexHandler))
+	http.HandleFunc("/douban", withFeature(doubanAPIEnabled, func(w http.ResponseWriter, r *http.Request) {
+		components.DoubanHandler(w, r, currentConfig())
+	}))
+	http.HandleFunc("/api/admin/reload", configReloader.HandleReloadAPI)
+	http.HandleFunc("/api/admin/sources", configReloader.HandleAdminSourcesAPI)
 
 	// 添加视频源API路由
 	http.HandleFunc("/api/sources", sourcesConfig.HandleSourcesAPI)
 	http.HandleFunc("/api/source_search", sourcesConfig.HandleSourceSearchAPI)
+	http.HandleFunc("/api/aggregate_search", sourcesConfig.HandleAggregateSearchAPI)
+	http.HandleFunc("/api/source_search_stream", sourcesConfig.HandleSourceSearchStreamAPI)
+	http.HandleFunc("/api/source_detail", sourcesConfig.HandleSourceDetailAPI)
+	http.HandleFunc("/api/source_categories", sourcesConfig.HandleSourceCategoriesAPI)
 
 	// 添加过滤配置API路由
 	http.HandleFunc("/api/filter_config", filterConfigHandler)
 
 	// 新增：资源检测页面和SSE流
 	http.HandleFunc("/check_sources", checkSourcesPageHandler)
-	// 移除 http.HandleFunc("/check_sources/stream", ...) 及 checkSourcesStreamHandler 相关实现
+	http.HandleFunc("/check_sources/stream", checkSourcesStreamHandler)
 
 	// 添加 scorpio 源 API 路由
 	http.HandleFunc("/api/scorpio_sources", components.HandleScorpioSourcesAPI)
@@ -335,20 +492,88 @@ func main() {
 	}
 }
 
-// LoadConfig 加载配置文件
-func LoadConfig() error {
-	configData, err := ConfigContent.ReadFile("config/config.ini")
+// readConfigData 确定并读取配置文件，未找到外部配置文件时返回内置配置，此时路径为空
+func readConfigData(flagPath string) (string, []byte, error) {
+	path, err := utils.FindConfigFile(flagPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var configData []byte
+	if path != "" {
+		configData, err = os.ReadFile(path)
+	} else {
+		// 未找到外部配置文件时使用编译时内置的配置
+		configData, err = ConfigContent.ReadFile("config/config.ini")
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	return path, configData, nil
+}
+
+// LoadConfig 加载并检查配置文件，返回配置内容供视频源解析；未找到外部配置文件时使用内置配置
+func LoadConfig(flagPath string) ([]byte, error) {
+	path, configData, err := readConfigData(flagPath)
 	if err != nil {
-		return fmt.Errorf("读取配置文件失败: %v", err)
+		return nil, err
 	}
 
 	config, err := utils.LoadConfigFromData(configData)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	config.ResolvePaths(path)
+	issues := components.ValidateConfig(config, configData)
+	for _, issue := range issues {
+		if issue.Warning {
+			log.Printf("⚠️ 配置警告 %s", issue)
+		} else {
+			log.Printf("❌ 配置错误 %s", issue)
+		}
+	}
+	if err := issues.Err(); err != nil {
+		return nil, err
 	}
 
 	GlobalConfig = config
-	return nil
+	ConfigPath = path
+	return configData, nil
+}
+
+// runConfigCheck 检查配置文件并输出全部问题，返回进程退出码：存在错误时为 1
+func runConfigCheck(flagPath string) int {
+	path, configData, err := readConfigData(flagPath)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if path == "" {
+		path = "内置配置"
+	}
+	fmt.Printf("📄 检查配置文件: %s\n", path)
+
+	config, err := utils.LoadConfigFromData(configData)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	issues := components.ValidateConfig(config, configData).Strict()
+	for _, issue := range issues {
+		if issue.Warning {
+			fmt.Printf("⚠️  %s\n", issue)
+		} else {
+			fmt.Printf("❌ %s\n", issue)
+		}
+	}
+
+	errs := len(issues.Errors())
+	if errs > 0 {
+		fmt.Printf("❌ 配置检查未通过: %d 个错误, %d 个警告\n", errs, len(issues)-errs)
+		return 1
+	}
+	fmt.Printf("✅ 配置检查通过 (%d 个警告)\n", len(issues))
+	return 0
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -454,11 +679,11 @@ func filterConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 返回过滤配置
+	cfg := currentConfig()
 	response := map[string]interface{}{
 		"success": true,
 		"data": map[string]interface{}{
-			"admin_password":       GlobalConfig.Filter.AdminPassword,
-			"default_adult_filter": GlobalConfig.Filter.DefaultAdultFilter,
+			"default_adult_filter": cfg.Filter.DefaultAdultFilter,
 		},
 	}
 
@@ -3,10 +3,24 @@ package utils
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"gopkg.in/ini.v1"
 )
 
+// HeaderRule 按主机匹配的上游请求头规则，来自 [headers.<名称>] 配置段
+type HeaderRule struct {
+	Name  string
+	Hosts []string          // 主机匹配规则：example.com 匹配该域名及子域名，*.example.com 仅匹配子域名，* 匹配全部
+	Set   map[string]string // set.<头部> = 值，覆盖同名头部
+	Add   map[string]string // add.<头部> = 值，追加头部
+	Strip []string          // 需要移除的头部
+}
+
 // Config 配置结构体
 type Config struct {
 	Server struct {
@@ -18,6 +32,16 @@ type Config struct {
 		UserAgent          string `ini:"user_agent"`
 		MaxRedirects       int    `ini:"max_redirects"`
 		DisableCompression bool   `ini:"disable_compression"`
+		UpstreamProxy      string `ini:"upstream_proxy"`
+		ForwardCookies     bool   `ini:"forward_cookies"`
+		AllowedSchemes     string `ini:"allowed_schemes"`
+		BlockPrivate       bool   `ini:"block_private"`
+		AllowDomains       string `ini:"allow_domains"`
+		DenyDomains        string `ini:"deny_domains"`
+		MaxResponseSizeMB  int    `ini:"max_response_size_mb"`
+		RequireSignature   bool   `ini:"require_signature"`
+		SignatureTTL       int    `ini:"signature_ttl"`
+		SignatureKeyFile   string `ini:"signature_key_file"`
 	} `ini:"proxy"`
 	Browser struct {
 		AutoOpen bool `ini:"auto_open"`
@@ -44,6 +68,42 @@ type Config struct {
 		AdminPassword      string `ini:"admin_password"`
 		DefaultAdultFilter bool   `ini:"default_adult_filter"`
 	} `ini:"filter"`
+	Admin struct {
+		// Token 管理接口令牌，为空时管理接口不可用
+		Token string `ini:"token"`
+	} `ini:"admin"`
+	AdFilter struct {
+		Enabled       bool    `ini:"enabled"`
+		HostMismatch  bool    `ini:"host_mismatch"`
+		PathMismatch  bool    `ini:"path_mismatch"`
+		AdDurations   string  `ini:"ad_durations"`
+		MaxAdDuration float64 `ini:"max_ad_duration"`
+		// Rules 按域名配置的分片过滤正则，来自 rule.<域名> = <正则>
+		Rules map[string]string `ini:"-"`
+	} `ini:"adfilter"`
+	Cache struct {
+		Enabled         bool   `ini:"enabled"`
+		Dir             string `ini:"dir"`
+		MaxSizeMB       int    `ini:"max_size_mb"`
+		LivePlaylistTTL int    `ini:"live_playlist_ttl"`
+		VODPlaylistTTL  int    `ini:"vod_playlist_ttl"`
+		SegmentTTL      int    `ini:"segment_ttl"`
+	} `ini:"cache"`
+	Prefetch struct {
+		Enabled     bool `ini:"enabled"`
+		Depth       int  `ini:"depth"`
+		Concurrency int  `ini:"concurrency"`
+		BufferMB    int  `ini:"buffer_mb"`
+		IdleTimeout int  `ini:"idle_timeout"`
+	} `ini:"prefetch"`
+	Download struct {
+		Enabled     bool   `ini:"enabled"`
+		Dir         string `ini:"dir"`
+		MaxJobs     int    `ini:"max_jobs"`
+		Concurrency int    `ini:"concurrency"`
+	} `ini:"download"`
+	// HeaderRules 上游请求头规则，按配置文件中的顺序依次应用
+	HeaderRules []HeaderRule `ini:"-"`
 }
 
 // LoadConfigFromData 从配置数据加载配置
@@ -54,11 +114,223 @@ func LoadConfigFromData(configData []byte) (*Config, error) {
 	}
 
 	var config Config
+	// 配置文件中缺少的项保持以下默认值
+	config.Proxy.BlockPrivate = true
 	err = cfg.MapTo(&config)
 	if err != nil {
 		return nil, fmt.Errorf("映射配置失败: %v", err)
 	}
 
+	// 环境变量优先于配置文件
+	applied, err := applyEnvOverrides(&config)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range applied {
+		log.Printf("⚙️ 环境变量覆盖配置: %s", name)
+	}
+
+	// 解析广告过滤的域名规则
+	config.AdFilter.Rules = make(map[string]string)
+	for _, key := range cfg.Section("adfilter").Keys() {
+		if domain := strings.TrimPrefix(key.Name(), "rule."); domain != key.Name() && domain != "" {
+			config.AdFilter.Rules[strings.ToLower(domain)] = key.String()
+		}
+	}
+
+	// 解析上游请求头规则
+	for _, section := range cfg.Sections() {
+		name := strings.TrimPrefix(section.Name(), "headers.")
+		if name == section.Name() || name == "" {
+			continue
+		}
+		rule := HeaderRule{Name: name, Set: make(map[string]string), Add: make(map[string]string)}
+		for _, key := range section.Keys() {
+			switch keyName := key.Name(); {
+			case keyName == "hosts":
+				rule.Hosts = splitCSV(key.String())
+			case keyName == "strip":
+				rule.Strip = splitCSV(key.String())
+			case strings.HasPrefix(keyName, "set."):
+				rule.Set[strings.TrimPrefix(keyName, "set.")] = key.String()
+			case strings.HasPrefix(keyName, "add."):
+				rule.Add[strings.TrimPrefix(keyName, "add.")] = key.String()
+			}
+		}
+		if len(rule.Hosts) == 0 {
+			log.Printf("⚠️ 请求头规则 %s 未配置 hosts，已忽略", name)
+			continue
+		}
+		config.HeaderRules = append(config.HeaderRules, rule)
+	}
+
 	log.Printf("✅ 配置文件加载成功")
 	return &config, nil
 }
+
+// DefaultSignatureKeyFile 默认的代理链接签名密钥文件，与配置文件放在同一目录
+const DefaultSignatureKeyFile = "proxy_signature.key"
+
+// ResolvePaths 将签名密钥、缓存目录和下载目录中的相对路径解析为相对于配置文件所在目录，
+// 与启动时的工作目录无关；使用内置配置（configPath 为空）时保持相对于工作目录
+func (c *Config) ResolvePaths(configPath string) {
+	if configPath == "" {
+		return
+	}
+	if c.Proxy.SignatureKeyFile == "" {
+		c.Proxy.SignatureKeyFile = DefaultSignatureKeyFile
+	}
+	dir := filepath.Dir(configPath)
+	for _, path := range []*string{&c.Proxy.SignatureKeyFile, &c.Cache.Dir, &c.Download.Dir} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+// ConfigEnvVar 指定配置文件路径的环境变量
+const ConfigEnvVar = "VASTVIDEO_CONFIG"
+
+// ConfigSearchPaths 未指定配置文件时依次查找的路径：工作目录、可执行文件所在目录、/etc/vastvideo
+func ConfigSearchPaths() []string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		for _, path := range []string{filepath.Join(dir, "config.ini"), filepath.Join(dir, "config", "config.ini")} {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return append(paths, "/etc/vastvideo/config.ini")
+}
+
+// FindConfigFile 确定要加载的配置文件：命令行参数优先，其次为 VASTVIDEO_CONFIG 环境变量，
+// 最后按 ConfigSearchPaths 查找。显式指定的文件不存在时返回错误，均未找到时返回空字符串
+func FindConfigFile(flagPath string) (string, error) {
+	explicit, from := flagPath, "-config"
+	if explicit == "" {
+		explicit, from = os.Getenv(ConfigEnvVar), ConfigEnvVar
+	}
+	if explicit != "" {
+		info, err := os.Stat(explicit)
+		if err != nil {
+			return "", fmt.Errorf("%s 指定的配置文件不可用: %v", from, err)
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("%s 指定的配置文件是目录: %s", from, explicit)
+		}
+		return explicit, nil
+	}
+
+	for _, path := range ConfigSearchPaths() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// EnvPrefix 覆盖配置项的环境变量前缀
+const EnvPrefix = "VASTVIDEO_"
+
+// SourcesEnvVar 追加或覆盖视频源的环境变量，内容为 JSON 数组或 [sources] 格式的 INI
+const SourcesEnvVar = "VASTVIDEO_SOURCES"
+
+// EnvVarName 配置项对应的环境变量名，例如 server.port → VASTVIDEO_SERVER_PORT
+func EnvVarName(section, key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(section+"_"+key, ".", "_"))
+}
+
+// applyEnvOverrides 按 ini 标签遍历配置项，用存在的 VASTVIDEO_<段>_<键> 环境变量覆盖，返回已应用的变量名
+func applyEnvOverrides(config *Config) ([]string, error) {
+	var applied []string
+	root := reflect.ValueOf(config).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i).Tag.Get("ini")
+		sectionValue := root.Field(i)
+		if section == "" || section == "-" || sectionValue.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < sectionValue.NumField(); j++ {
+			key := sectionValue.Type().Field(j).Tag.Get("ini")
+			if key == "" || key == "-" {
+				continue
+			}
+			name := EnvVarName(section, key)
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if err := setFieldFromString(sectionValue.Field(j), value); err != nil {
+				return nil, fmt.Errorf("环境变量 %s 无效: %v", name, err)
+			}
+			applied = append(applied, name)
+		}
+	}
+	return applied, nil
+}
+
+// setFieldFromString 按字段类型解析字符串并赋值
+func setFieldFromString(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("需要整数: %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("需要数字: %q", value)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("不支持的配置类型: %s", field.Kind())
+	}
+	return nil
+}
+
+// parseBool 解析布尔值，与 INI 一致接受 true/false、1/0、yes/no、on/off
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("需要布尔值: %q", value)
+}
+
+// splitCSV 拆分逗号分隔的配置值
+func splitCSV(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
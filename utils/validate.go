@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// ConfigIssue 配置检查发现的问题
+type ConfigIssue struct {
+	Key     string // 配置项，如 server.port、sources.bfzy.url
+	Message string
+	Warning bool // 仅为警告，不影响启动
+	Strict  bool // 启动和热重载时作为警告并跳过该项，-check-config 时视为错误
+}
+
+func (i ConfigIssue) String() string {
+	if i.Key == "" {
+		return i.Message
+	}
+	return i.Key + ": " + i.Message
+}
+
+// ConfigIssues 配置检查结果
+type ConfigIssues []ConfigIssue
+
+// Errors 返回错误（不含警告）
+func (issues ConfigIssues) Errors() ConfigIssues {
+	var errs ConfigIssues
+	for _, issue := range issues {
+		if !issue.Warning {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// Strict 返回 -check-config 使用的检查结果，Strict 问题视为错误
+func (issues ConfigIssues) Strict() ConfigIssues {
+	strict := make(ConfigIssues, len(issues))
+	for i, issue := range issues {
+		if issue.Strict {
+			issue.Warning = false
+		}
+		strict[i] = issue
+	}
+	return strict
+}
+
+// Err 存在错误时返回汇总了全部错误的 error
+func (issues ConfigIssues) Err() error {
+	errs := issues.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	lines := make([]string, len(errs))
+	for i, issue := range errs {
+		lines[i] = issue.String()
+	}
+	return fmt.Errorf("配置检查发现 %d 个错误: %s", len(errs), strings.Join(lines, "; "))
+}
+
+// 允许的日志级别
+var validLogLevels = map[string]bool{"debug": true, "info": true, "warn": true, "warning": true, "error": true}
+
+// ValidateConfig 检查配置文件中的段名、键名和取值类型，以及加载后（含环境变量覆盖）的配置取值；
+// [sources] 由视频源模块检查
+func ValidateConfig(config *Config, configData []byte) ConfigIssues {
+	file, err := ini.Load(configData)
+	if err != nil {
+		return ConfigIssues{{Message: fmt.Sprintf("解析配置文件失败: %v", err)}}
+	}
+
+	issues := checkConfigKeys(file)
+	issues = append(issues, checkConfigValues(config)...)
+	return issues
+}
+
+// checkConfigKeys 检查未知的段和键，以及无法转换为字段类型的取值
+func checkConfigKeys(file *ini.File) ConfigIssues {
+	var issues ConfigIssues
+
+	sections := make(map[string]reflect.Type)
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		if tag := field.Tag.Get("ini"); tag != "" && tag != "-" && field.Type.Kind() == reflect.Struct {
+			sections[tag] = field.Type
+		}
+	}
+
+	for _, section := range file.Sections() {
+		name := section.Name()
+		switch {
+		case name == ini.DefaultSection || name == "sources":
+			continue
+		case strings.HasPrefix(name, "headers."):
+			issues = append(issues, checkHeaderRuleKeys(section)...)
+			continue
+		}
+
+		sectionType, ok := sections[name]
+		if !ok {
+			issues = append(issues, ConfigIssue{Key: "[" + name + "]", Message: "未知的配置段", Warning: true})
+			continue
+		}
+
+		fields := make(map[string]reflect.Kind)
+		for i := 0; i < sectionType.NumField(); i++ {
+			if tag := sectionType.Field(i).Tag.Get("ini"); tag != "" && tag != "-" {
+				fields[tag] = sectionType.Field(i).Type.Kind()
+			}
+		}
+		for _, key := range section.Keys() {
+			keyName := name + "." + key.Name()
+			if name == "adfilter" && strings.HasPrefix(key.Name(), "rule.") {
+				if _, err := regexp.Compile(key.String()); err != nil {
+					issues = append(issues, ConfigIssue{Key: keyName, Message: fmt.Sprintf("正则表达式无效: %v", err)})
+				}
+				continue
+			}
+			kind, ok := fields[key.Name()]
+			if !ok {
+				issues = append(issues, ConfigIssue{Key: keyName, Message: "未知的配置项"})
+				continue
+			}
+			if msg := checkValueKind(key, kind); msg != "" {
+				issues = append(issues, ConfigIssue{Key: keyName, Message: msg})
+			}
+		}
+	}
+	return issues
+}
+
+// checkValueKind 检查取值能否转换为字段类型，MapTo 会把无法转换的值静默设为零值
+func checkValueKind(key *ini.Key, kind reflect.Kind) string {
+	if strings.TrimSpace(key.String()) == "" {
+		return ""
+	}
+	switch kind {
+	case reflect.Bool:
+		if _, err := key.Bool(); err != nil {
+			return fmt.Sprintf("需要布尔值 (true/false)，当前为 %q", key.String())
+		}
+	case reflect.Int, reflect.Int64:
+		if _, err := key.Int64(); err != nil {
+			return fmt.Sprintf("需要整数，当前为 %q", key.String())
+		}
+	case reflect.Float64:
+		if _, err := key.Float64(); err != nil {
+			return fmt.Sprintf("需要数字，当前为 %q", key.String())
+		}
+	}
+	return ""
+}
+
+// checkHeaderRuleKeys 检查 [headers.<名称>] 请求头规则
+func checkHeaderRuleKeys(section *ini.Section) ConfigIssues {
+	var issues ConfigIssues
+	name := section.Name()
+	if !section.HasKey("hosts") || len(splitCSV(section.Key("hosts").String())) == 0 {
+		issues = append(issues, ConfigIssue{Key: name + ".hosts", Message: "未配置 hosts，该规则不会生效", Warning: true})
+	}
+	for _, key := range section.Keys() {
+		keyName := key.Name()
+		switch {
+		case keyName == "hosts" || keyName == "strip":
+		case strings.HasPrefix(keyName, "set.") && len(keyName) > len("set."):
+		case strings.HasPrefix(keyName, "add.") && len(keyName) > len("add."):
+		default:
+			issues = append(issues, ConfigIssue{Key: name + "." + keyName, Message: "未知的配置项，应为 hosts、strip、set.<头部> 或 add.<头部>"})
+		}
+	}
+	return issues
+}
+
+// checkConfigValues 检查加载后的配置取值
+func checkConfigValues(config *Config) ConfigIssues {
+	var issues ConfigIssues
+	add := func(key, format string, args ...interface{}) {
+		issues = append(issues, ConfigIssue{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if port, err := strconv.Atoi(strings.TrimSpace(config.Server.Port)); err != nil || port < 1 || port > 65535 {
+		add("server.port", "端口需要为 1-65535 之间的整数，当前为 %q", config.Server.Port)
+	}
+	if level := strings.ToLower(strings.TrimSpace(config.Logging.LogLevel)); level != "" && !validLogLevels[level] {
+		add("logging.log_level", "日志级别需要为 debug、info、warn 或 error，当前为 %q", config.Logging.LogLevel)
+	}
+	if config.Logging.FileOutput && strings.TrimSpace(config.Logging.LogFile) == "" {
+		add("logging.log_file", "已开启 file_output，但未设置日志文件")
+	}
+	for _, scheme := range splitCSV(config.Proxy.AllowedSchemes) {
+		if s := strings.ToLower(scheme); s != "http" && s != "https" {
+			add("proxy.allowed_schemes", "仅支持 http 和 https，当前包含 %q", scheme)
+		}
+	}
+	for _, duration := range splitCSV(config.AdFilter.AdDurations) {
+		if _, err := strconv.ParseFloat(duration, 64); err != nil {
+			add("adfilter.ad_durations", "需要逗号分隔的秒数，%q 无效", duration)
+		}
+	}
+
+	// 数值配置均不能为负数
+	root := reflect.ValueOf(config).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i).Tag.Get("ini")
+		sectionValue := root.Field(i)
+		if section == "" || section == "-" || sectionValue.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < sectionValue.NumField(); j++ {
+			key := sectionValue.Type().Field(j).Tag.Get("ini")
+			field := sectionValue.Field(j)
+			negative := false
+			switch field.Kind() {
+			case reflect.Int, reflect.Int64:
+				negative = field.Int() < 0
+			case reflect.Float64:
+				negative = field.Float() < 0
+			}
+			if negative {
+				add(section+"."+key, "不能为负数")
+			}
+		}
+	}
+	return issues
+}